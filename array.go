@@ -34,8 +34,7 @@ func newTypedArray[T Number](c *Context, slice []T, arrayType int) C.JSValue {
 type Array struct{ Object }
 
 func (a Array) Len() int {
-	property, _ := a.GetProperty("length")
-	return property.ToPrimitive().(int)
+	return a.context.consumeNative(a.getProperty("length")).(int)
 }
 
 func (a Array) Set(index int, value any) {
@@ -50,19 +49,29 @@ func (a Array) ToNative() []any {
 	length := a.Len()
 	retval := make([]any, 0, length)
 	for i := 0; i < length; i++ {
-		retval = append(retval, a.Get(i).ToNative())
+		retval = append(retval, a.context.consumeNative(a.getPropertyByIndex(uint32(i))))
 	}
 	return retval
 }
 
+// Result of Array.from(value), owned by caller
+func (c *Context) arrayFrom(value C.JSValue) C.JSValue {
+	array := c.GlobalObject().getProperty("Array")
+	from := Value{c, array}.Object().getProperty("from")
+	retval := Value{c, from}.Object().call(null, 1, &value)
+	C.JS_FreeValue(c.raw, from)
+	C.JS_FreeValue(c.raw, array)
+	return retval
+}
+
 type ArrayBuffer struct{ Object }
 
 func (b ArrayBuffer) Len() int {
-	property, err := b.GetProperty("byteLength")
-	if err != nil {
+	property := b.getProperty("byteLength")
+	if err := b.context.checkException(property); err != nil {
 		panic(err)
 	}
-	return property.ToNative().(int)
+	return b.context.consumeNative(property).(int)
 }
 
 func (b ArrayBuffer) ToNative() []byte {
@@ -74,8 +83,7 @@ func (b ArrayBuffer) ToNative() []byte {
 type TypedArray[T Number] struct{ Object }
 
 func (a TypedArray[T]) Len() int {
-	property, _ := a.GetProperty("length")
-	return property.ToNative().(int)
+	return a.context.consumeNative(a.getProperty("length")).(int)
 }
 
 func (a TypedArray[T]) ToNative() []T {
@@ -0,0 +1,139 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"context"
+	"sync"
+)
+
+// Outcome of AsyncFunc, Value is converted with ToValue
+type Result struct {
+	Value any
+	Err   error
+}
+
+// Called on runtime thread where arguments should be extracted, blocking work
+// is expected to be done by other goroutine sending result to returned channel.
+// Promise returned to javascript is settled on runtime thread by the result,
+// or resolved with undefined if channel is closed without result.
+type AsyncFunc = func(call Call) <-chan Result
+
+// Same as AsyncFunc, blocking work should stop once ctx is done, which happens
+// when context is freed, parent given to AsyncContext is done, or the promise
+// is settled
+type AsyncContextFunc = func(ctx context.Context, call Call) <-chan Result
+
+// Run fn on a new goroutine and deliver its outcome as Result
+func Go(fn func() (any, error)) <-chan Result {
+	retval := make(chan Result, 1)
+	go func() {
+		value, err := fn()
+		retval <- Result{value, err}
+	}()
+	return retval
+}
+
+// Jobs posted by other goroutines to be executed on runtime thread
+type jobQueue struct {
+	mutex  sync.Mutex
+	jobs   []func()
+	notify chan struct{}
+}
+
+func (q *jobQueue) post(job func()) {
+	q.mutex.Lock()
+	q.jobs = append(q.jobs, job)
+	q.mutex.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *jobQueue) take() []func() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	jobs := q.jobs
+	q.jobs = nil
+	return jobs
+}
+
+// Execute jobs posted by other goroutines, return false if there is none
+func (r *Runtime) runPostedJobs() bool {
+	jobs := r.posted.take()
+	for _, job := range jobs {
+		job()
+	}
+	return len(jobs) > 0
+}
+
+// Promise returned by AsyncFunc and not yet settled
+type asyncCall struct {
+	context   *Context
+	resolving [2]C.JSValue // resolve and reject functions
+	cancel    context.CancelFunc
+}
+
+func (a *asyncCall) free() {
+	a.cancel()
+	delete(a.context.runtime.asyncCalls, a)
+	C.JS_FreeValue(a.context.raw, a.resolving[0])
+	C.JS_FreeValue(a.context.raw, a.resolving[1])
+}
+
+func (a *asyncCall) settle(result Result) {
+	c := a.context
+	if _, ok := c.runtime.asyncCalls[a]; !ok {
+		return // Released by Context.Free
+	}
+	fn, value := a.resolving[0], c.toValue(result.Value)
+	if result.Err != nil {
+		C.JS_FreeValue(c.raw, value)
+		fn, value = a.resolving[1], c.errorValue(result.Err)
+	}
+	C.JS_FreeValue(c.raw, C.JS_Call(c.raw, fn, null, 1, &value))
+	C.JS_FreeValue(c.raw, value)
+	a.free()
+}
+
+// Expose AsyncFunc as Func returning promise, e.g. SetFunc("query", Async(fn))
+func Async(fn AsyncFunc) Func {
+	return AsyncContext(context.Background(), func(_ context.Context, call Call) <-chan Result {
+		return fn(call)
+	})
+}
+
+// Same as Async but fn is given ctx derived from parent, promise is rejected
+// with error of ctx once parent is done before result is sent
+func AsyncContext(parent context.Context, fn AsyncContextFunc) Func {
+	return func(call Call) (Value, error) {
+		c := call.Context
+		ctx, cancel := context.WithCancel(parent)
+		pending := &asyncCall{context: c, cancel: cancel}
+		promise := C.JS_NewPromiseCapability(c.raw, &pending.resolving[0])
+		if err := c.checkException(promise); err != nil {
+			cancel()
+			return c.ToValue(Undefined), err
+		}
+		c.runtime.asyncCalls[pending] = struct{}{}
+		results := fn(ctx, call)
+		if results == nil {
+			pending.settle(Result{Err: ErrNilChannel})
+			return Value{c, promise}, nil
+		}
+		go func() {
+			var result Result
+			select {
+			case received, ok := <-results:
+				if result = received; !ok {
+					result.Value = Undefined
+				}
+			case <-ctx.Done(): // Released already if context is freed
+				result.Err = ctx.Err()
+			}
+			c.runtime.posted.post(func() { pending.settle(result) })
+		}()
+		return Value{c, promise}, nil
+	}
+}
@@ -0,0 +1,111 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	release := make(chan struct{})
+	NewRuntime().NewContext().With(func(context *Context) {
+		context.GlobalObject().SetFunc("query", Async(func(call Call) <-chan Result {
+			key := call.Arg(0).String()
+			return Go(func() (any, error) {
+				<-release
+				if key == "missing" {
+					return nil, errors.New("not found")
+				}
+				return "value of " + key, nil
+			})
+		}))
+		context.GlobalObject().SetFunc("noop", Async(func(call Call) <-chan Result {
+			retval := make(chan Result)
+			close(retval)
+			return retval
+		}))
+
+		value, err := context.Eval(`(async () => await query("a") + ", " + await noop())()`)
+		assert.NoError(t, err)
+		promise := value.Dup()
+		assert.NoError(t, context.RunPendingJobs())
+		assert.Equal(t, PromisePending, promise.Object().Promise().State())
+		close(release)
+		result, err := promise.Await(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "value of a, undefined", result.ToNative())
+		promise.Free()
+
+		value, _ = context.Eval(`query("missing").catch(e => e.message)`)
+		result, err = value.Await(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "not found", result.ToNative())
+	})
+}
+
+func TestAsyncFuncContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	runtime := NewRuntime(Config{ManualFree: true, Debug: true, LeakHandler: FailOnLeak(t)})
+	guard := runtime.NewContext()
+	guard.With(func(context *Context) {
+		context.GlobalObject().SetFunc("wait", Async(func(call Call) <-chan Result {
+			return Go(func() (any, error) { <-release; return nil, nil })
+		}))
+		value, _ := context.Eval(`wait()`)
+		_, err := value.Await(ctx)
+		assert.ErrorIs(t, err, ErrInterrupted)
+		assert.ErrorIs(t, err, ctx.Err())
+	})
+	guard.Free() // Pending promise is released
+	runtime.Free()
+}
+
+func TestAsyncContext(t *testing.T) {
+	timeout, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	parent, cancelParent := context.WithCancel(context.Background())
+	contexts := make(chan context.Context, 1)
+	wait := func(ctx context.Context, call Call) <-chan Result {
+		contexts <- ctx
+		return Go(func() (any, error) { <-ctx.Done(); return nil, nil })
+	}
+	cancelled := AsyncContext(parent, wait)
+	freed := AsyncContext(context.Background(), wait)
+	runtime := NewRuntime(Config{ManualFree: true, Debug: true, LeakHandler: FailOnLeak(t)})
+	guard := runtime.NewContext()
+	var ctx context.Context
+	guard.With(func(context *Context) {
+		context.GlobalObject().SetFunc("nothing", Async(func(Call) <-chan Result { return nil }))
+		value, _ := context.Eval(`nothing()`)
+		_, err := value.Await(timeout)
+		assert.ErrorIs(t, err, ErrNilChannel)
+
+		context.GlobalObject().SetFunc("cancelled", cancelled)
+		value, _ = context.Eval(`cancelled()`)
+		<-contexts
+		cancelParent()
+		_, err = value.Await(timeout)
+		assert.ErrorIs(t, err, parent.Err())
+
+		context.GlobalObject().SetFunc("freed", freed)
+		_, err = context.Eval(`freed()`)
+		assert.NoError(t, err)
+		ctx = <-contexts
+		assert.NoError(t, ctx.Err())
+	})
+	guard.Free()
+	select {
+	case <-ctx.Done():
+	case <-timeout.Done():
+		t.Error("ctx of pending call is not cancelled by Context.Free")
+	}
+	runtime.Free()
+}
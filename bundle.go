@@ -0,0 +1,144 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import "fmt"
+
+// First element of bundle array, distinguishing bundle from array constant
+const bundleMagic = "go-quickjs bundle"
+
+// Dependencies recorded while compiling bundle, serialized as array of
+// bundleMagic, resolutions, dependencies and entry module
+type bundle struct {
+	resolved []string // Triples of base, specifier and resolved name
+	modules  []*C.JSModuleDef
+}
+
+// Compile module named entry together with all modules it imports into single
+// ByteCode, modules are loaded by loader in a scratch runtime. The bundle is
+// evaluated by EvalBinary or EvalModuleBinary without calling module loader.
+// Modules registered by NewModule are not bundled and must be registered in
+// context evaluating the bundle.
+func (c *Context) CompileBundle(entry string, loader ModuleLoader) (ByteCode, error) {
+	config := DefaultConfig()
+	config.ManualFree = true
+	jsRuntime := NewRuntime(config)
+	defer jsRuntime.Free()
+	jsRuntime.SetModuleLoader(loader)
+	scratch := jsRuntime.NewContext().Unwrap()
+	defer scratch.Free()
+	for _, name := range c.nativeModules {
+		if err := scratch.NewModule(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return scratch.compileBundle(entry, loader)
+}
+
+func (c *Context) compileBundle(entry string, loader ModuleLoader) (ByteCode, error) {
+	code, err := loader.Load(entry)
+	if err != nil {
+		return nil, err
+	}
+	c.bundle = &bundle{}
+	defer func() { c.bundle = nil }()
+	module := c.compileModule(c.raw, entry, code)
+	if module == nil {
+		return nil, c.getException()
+	}
+	if C.JS_ResolveModule(c.raw, C.JS_ModuleValue(module)) < 0 {
+		return nil, c.getException()
+	}
+	resolved := C.JS_NewArray(c.raw)
+	for i, text := range c.bundle.resolved {
+		C.JS_SetPropertyUint32(c.raw, resolved, C.uint32_t(i), c.toValue(text))
+	}
+	array := C.JS_NewArray(c.raw)
+	C.JS_SetPropertyUint32(c.raw, array, 0, c.toValue(bundleMagic))
+	C.JS_SetPropertyUint32(c.raw, array, 1, resolved)
+	for i, module := range append(c.bundle.modules, module) {
+		value := C.JS_DupValue(c.raw, C.JS_ModuleValue(module))
+		C.JS_SetPropertyUint32(c.raw, array, C.uint32_t(i+2), value)
+	}
+	return c.writeByteCode(array)
+}
+
+// Elements of deserialized array, nil if value is not an array
+func (c *Context) arrayElements(array C.JSValue) []C.JSValue {
+	var length C.uint32_t
+	if C.JS_IsArray(c.raw, array) != 1 {
+		return nil
+	}
+	lengthValue := C.JS_GetPropertyStr(c.raw, array, strPtr("length\x00"))
+	defer C.JS_FreeValue(c.raw, lengthValue)
+	if C.JS_ToUint32(c.raw, &length, lengthValue) < 0 {
+		return nil
+	}
+	elements := make([]C.JSValue, length)
+	for i := range elements {
+		elements[i] = C.JS_GetPropertyUint32(c.raw, array, C.uint32_t(i))
+	}
+	return elements
+}
+
+func (c *Context) freeElements(elements []C.JSValue) {
+	for _, element := range elements {
+		C.JS_FreeValue(c.raw, element)
+	}
+}
+
+// Check shape of bundle and return its resolutions
+func (c *Context) bundleResolutions(elements []C.JSValue) (map[[2]string]string, error) {
+	malformed := fmt.Errorf("%w: malformed bundle", ErrInvalidByteCode)
+	if len(elements) < 3 || C.JS_IsString(elements[0]) == 0 {
+		return nil, malformed
+	}
+	if magic := (Value{c, elements[0]}).String(); magic != bundleMagic {
+		return nil, malformed
+	}
+	for _, module := range elements[2:] {
+		if C.JS_IsModule(module) == 0 {
+			return nil, malformed
+		}
+	}
+	texts := c.arrayElements(elements[1])
+	defer c.freeElements(texts)
+	if texts == nil || len(texts)%3 != 0 {
+		return nil, malformed
+	}
+	resolved := make(map[[2]string]string, len(texts)/3)
+	for i := 0; i < len(texts); i += 3 {
+		for _, text := range texts[i : i+3] {
+			if C.JS_IsString(text) == 0 {
+				return nil, malformed
+			}
+		}
+		specifier := [2]string{Value{c, texts[i]}.String(), Value{c, texts[i+1]}.String()}
+		resolved[specifier] = Value{c, texts[i+2]}.String()
+	}
+	return resolved, nil
+}
+
+// Load modules of bundle into context, then resolve and return entry module.
+// Recorded resolutions only apply to imports of bundled modules resolved here
+func (c *Context) loadBundle(jsValue C.JSValue) (C.JSValue, error) {
+	defer C.JS_FreeValue(c.raw, jsValue)
+	elements := c.arrayElements(jsValue)
+	defer c.freeElements(elements)
+	resolved, err := c.bundleResolutions(elements)
+	if err != nil {
+		return C.JS_Exception(), err
+	}
+	c.resolved = resolved
+	defer func() { c.resolved = nil }()
+	// Bundled modules are resolved by recorded resolutions in any case
+	if c.runtime.loader == nil {
+		C.SetModuleLoader(c.runtime.raw, c.runtime.data)
+		defer C.SetModuleLoader(c.runtime.raw, nil)
+	}
+	entry := elements[len(elements)-1]
+	if C.JS_ResolveModule(c.raw, entry) < 0 {
+		return C.JS_Exception(), c.getException()
+	}
+	return C.JS_DupValue(c.raw, entry), nil
+}
@@ -0,0 +1,136 @@
+package quickjs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingLoader struct{ t *testing.T }
+
+func (f failingLoader) Resolve(base, specifier string) (string, error) {
+	f.t.Errorf("unexpected resolve %s from %s", specifier, base)
+	return specifier, nil
+}
+
+func (f failingLoader) Load(name string) (ModuleCode, error) {
+	f.t.Errorf("unexpected load %s", name)
+	return ModuleCode{}, nil
+}
+
+func TestCompileBundle(t *testing.T) {
+	files := fstest.MapFS{
+		"util.js":     {Data: []byte(`export const double = x => x * 2`)},
+		"lib/math.js": {Data: []byte(`export { double } from "../util.js"`)},
+		"main.js": {Data: []byte(`
+			import { double } from "./lib/math.js"
+			import { base } from "go:const"
+			export const result = double(base)`)},
+	}
+	var byteCode ByteCode
+	NewRuntime().NewContext().With(func(context *Context) {
+		assert.NoError(t, context.NewModule("go:const", map[string]any{"base": 0}))
+		var err error
+		byteCode, err = context.CompileBundle("main.js", FSLoader{files})
+		assert.NoError(t, err)
+
+		_, err = context.CompileBundle("missing.js", FSLoader{files})
+		assert.Error(t, err)
+		_, err = context.CompileBundle("lib/math.js", FSLoader{fstest.MapFS{}})
+		assert.Error(t, err)
+	})
+	header, err := byteCode.Header()
+	assert.NoError(t, err)
+	assert.True(t, header.Bundle)
+	assert.NoError(t, byteCode.Validate())
+
+	NewRuntime().NewContext().With(func(context *Context) {
+		_, err := context.EvalBinary(byteCode)
+		assert.Error(t, err) // go:const is not registered
+	})
+
+	NewRuntime().NewContext().With(func(context *Context) {
+		assert.NoError(t, context.NewModule("go:const", map[string]any{"base": 10}))
+		namespace, err := context.EvalModuleBinary(byteCode)
+		assert.NoError(t, err)
+		result, _ := namespace.GetProperty("result")
+		assert.Equal(t, 20, result.ToNative())
+	})
+
+	runtime := NewRuntime()
+	runtime.SetModuleLoader(failingLoader{t})
+	runtime.NewContext().With(func(context *Context) {
+		assert.NoError(t, context.NewModule("go:const", map[string]any{"base": 1}))
+		_, err = context.EvalBinary(byteCode)
+		assert.NoError(t, err)
+	})
+}
+
+type recordingLoader struct {
+	FSLoader
+	resolved []string
+}
+
+func (r *recordingLoader) Resolve(base, specifier string) (string, error) {
+	r.resolved = append(r.resolved, specifier)
+	return r.FSLoader.Resolve(base, specifier)
+}
+
+func TestBundleScope(t *testing.T) {
+	files := fstest.MapFS{
+		"util.js": {Data: []byte(`export const double = x => x * 2`)},
+		"main.js": {Data: []byte(`export { double } from "./util.js"`)},
+	}
+	var byteCode ByteCode
+	NewRuntime().NewContext().With(func(context *Context) {
+		var err error
+		byteCode, err = context.CompileBundle("main.js", FSLoader{files})
+		assert.NoError(t, err)
+	})
+
+	runtime := NewRuntime()
+	loader := &recordingLoader{FSLoader: FSLoader{files}}
+	runtime.SetModuleLoader(loader)
+	runtime.NewContext().With(func(context *Context) {
+		_, err := context.EvalModuleBinary(byteCode)
+		assert.NoError(t, err)
+		assert.Empty(t, loader.resolved)
+
+		// Later imports are resolved by loader instead of bundle resolutions
+		options := EvalOptions{Filename: "main.js", Type: EvalModule}
+		_, err = context.EvalWithOptions(`import { double } from "./util.js"`, options)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"./util.js"}, loader.resolved)
+	})
+}
+
+func TestBundleMarker(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		serialize := func(code string) ByteCode {
+			value, err := context.Eval(code)
+			assert.NoError(t, err)
+			owned := value.Dup()
+			defer owned.Free()
+			byteCode, err := context.writeByteCode(context.consume(owned))
+			assert.NoError(t, err)
+			return byteCode
+		}
+		header, err := serialize(`[[], 1]`).Header()
+		assert.NoError(t, err)
+		assert.False(t, header.Bundle)
+
+		for _, code := range []string{
+			`["go-quickjs bundle", [], 1]`,
+			`["go-quickjs bundle", ["a", "b"], 1]`,
+			`["go-quickjs bundle", {}, 1]`,
+		} {
+			byteCode := serialize(code)
+			header, err = byteCode.Header()
+			assert.NoError(t, err)
+			assert.True(t, header.Bundle)
+			_, err = context.EvalBinary(byteCode)
+			assert.ErrorIs(t, err, ErrInvalidByteCode, code)
+		}
+	})
+}
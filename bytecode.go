@@ -0,0 +1,474 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Serialization format version of bundled QuickJS, matches BC_VERSION in
+// libquickjs/quickjs.c. ByteCode produced by other versions can't be loaded
+// and must be recompiled from source.
+const ByteCodeVersion = C.BC_VERSION
+
+var (
+	// Returned when ByteCode is truncated or corrupted
+	ErrInvalidByteCode = errors.New("invalid bytecode")
+	// Returned when ByteCode is produced by different QuickJS version
+	ErrByteCodeVersion = errors.New("bytecode version mismatch")
+)
+
+// Tags of serialized values
+const (
+	bcTagNull              = C.BC_TAG_NULL
+	bcTagUndefined         = C.BC_TAG_UNDEFINED
+	bcTagBoolFalse         = C.BC_TAG_BOOL_FALSE
+	bcTagBoolTrue          = C.BC_TAG_BOOL_TRUE
+	bcTagInt32             = C.BC_TAG_INT32
+	bcTagFloat64           = C.BC_TAG_FLOAT64
+	bcTagString            = C.BC_TAG_STRING
+	bcTagObject            = C.BC_TAG_OBJECT
+	bcTagArray             = C.BC_TAG_ARRAY
+	bcTagBigInt            = C.BC_TAG_BIG_INT
+	bcTagTemplateObject    = C.BC_TAG_TEMPLATE_OBJECT
+	bcTagFunctionByteCode  = C.BC_TAG_FUNCTION_BYTECODE
+	bcTagModule            = C.BC_TAG_MODULE
+	bcTagTypedArray        = C.BC_TAG_TYPED_ARRAY
+	bcTagArrayBuffer       = C.BC_TAG_ARRAY_BUFFER
+	bcTagSharedArrayBuffer = C.BC_TAG_SHARED_ARRAY_BUFFER
+	bcTagDate              = C.BC_TAG_DATE
+	bcTagObjectValue       = C.BC_TAG_OBJECT_VALUE
+	bcTagObjectReference   = C.BC_TAG_OBJECT_REFERENCE
+	bcTagBigFloat          = C.BC_TAG_BIG_FLOAT
+	bcTagBigDecimal        = C.BC_TAG_BIG_DECIMAL
+)
+
+// Number of typed array classes
+const bcTypedArrayCount = C.JS_TYPED_ARRAY_COUNT
+
+// Number of builtin atoms, serialized atom indexes below it
+// are not stored in ByteCode
+const jsAtomEnd = C.JS_ATOM_END
+
+// has_debug bit in the second byte of function flags
+const bcFlagHasDebug = 1 << 2
+
+// Only allowed nesting of serialized values, deeper input is rejected
+// before it could overflow stack of byteCodeReader
+const bcMaxDepth = 1000
+
+// Information available without evaluating ByteCode
+type ByteCodeHeader struct {
+	// Serialization format version, equals ByteCodeVersion for loadable ByteCode
+	Version uint8
+	// Whether ByteCode is compiled as module
+	Module bool
+	// Whether ByteCode is compiled by CompileBundle
+	Bundle bool
+}
+
+// Read header of ByteCode, fails with ErrByteCodeVersion when ByteCode is
+// produced by different QuickJS version and ErrInvalidByteCode when
+// header is truncated
+func (b ByteCode) Header() (ByteCodeHeader, error) {
+	reader := byteCodeReader{data: b}
+	header, err := reader.header()
+	if err != nil {
+		return header, err
+	}
+	return header, reader.err
+}
+
+// Check header, version and checksum of ByteCode, which is also done before
+// loading. Checksum only detects accidental corruption, instructions of
+// functions are never verified, so ByteCode from untrusted sources is still
+// unsafe to evaluate.
+func (b ByteCode) Validate() error {
+	_, err := b.payload()
+	return err
+}
+
+// ByteCode is serialized values followed by their CRC-32, since JS_ReadObject
+// crashes instead of failing on some corrupted function bytecode
+func (b ByteCode) seal() ByteCode {
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+// Serialized values of ByteCode with valid header and checksum
+func (b ByteCode) payload() (ByteCode, error) {
+	if _, err := b.Header(); err != nil {
+		return nil, err
+	}
+	if len(b) < crc32.Size {
+		return nil, fmt.Errorf("%w: missing checksum", ErrInvalidByteCode)
+	}
+	payload, checksum := b[:len(b)-crc32.Size], b[len(b)-crc32.Size:]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(checksum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidByteCode)
+	}
+	return payload, nil
+}
+
+// Serialized size of function in ByteCode
+type FunctionSize struct {
+	Name     string // Empty for anonymous function
+	Filename string // Empty for stripped function
+	Line     int    // Zero for stripped function
+	ByteCode int    // Size of instructions in bytes
+	Debug    int    // Size of line number table in bytes
+	Size     int    // Total size in bytes, including nested functions
+}
+
+// Report size of each function in ByteCode by order of appearance,
+// context is only used to name functions with builtin atom names
+func (c *Context) FunctionSizes(byteCode ByteCode) ([]FunctionSize, error) {
+	payload, err := byteCode.payload()
+	if err != nil {
+		return nil, err
+	}
+	reader := byteCodeReader{data: payload, report: true}
+	reader.header()
+	if reader.value(0); reader.err != nil {
+		return nil, reader.err
+	}
+	retval := make([]FunctionSize, len(reader.functions))
+	for i, function := range reader.functions {
+		retval[i] = function.FunctionSize
+		retval[i].Name = c.atomName(&reader, function.name)
+		if function.filename != 0 {
+			retval[i].Filename = c.atomName(&reader, function.filename)
+		}
+	}
+	return retval, nil
+}
+
+func (c *Context) atomName(reader *byteCodeReader, index uint32) string {
+	if index&1 != 0 {
+		return strconv.FormatUint(uint64(index>>1), 10)
+	}
+	if index == 0 { // Name of anonymous function is set on evaluation
+		return ""
+	}
+	if index >>= 1; index >= jsAtomEnd {
+		return reader.names[index-jsAtomEnd]
+	}
+	return atom{c, C.JSAtom(index)}.String()
+}
+
+// Function reported with serialized atom indexes of name and filename
+type functionSize struct {
+	FunctionSize
+	name, filename uint32
+}
+
+// Mirror of BCReaderState which only checks structure
+type byteCodeReader struct {
+	data      []byte
+	pos       int
+	atoms     uint32
+	err       error
+	strip     bool // Collect debug information
+	report    bool // Collect atom names and functions
+	names     []string
+	functions []functionSize
+	debug     []debugInfo // Collected when stripping
+}
+
+// Location of debug information of serialized function
+type debugInfo struct {
+	flags      int // Position of flags holding has_debug
+	start, end int
+}
+
+// Clear has_debug flag of functions and remove their debug information,
+// which is placed after instructions and never referred by offset. Filename
+// stays in atom table of ByteCode, only references to it are removed
+func (b ByteCode) stripDebug() (ByteCode, error) {
+	payload, err := b.payload()
+	if err != nil {
+		return nil, err
+	}
+	reader := byteCodeReader{data: payload, strip: true}
+	reader.header()
+	if reader.value(0); reader.err != nil {
+		return nil, reader.err
+	}
+	// Debug information follows flags of its function, so flags are always
+	// copied before being cleared
+	retval, pos, removed := make(ByteCode, 0, len(b)), 0, 0
+	for _, debug := range reader.debug {
+		retval = append(retval, payload[pos:debug.start]...)
+		retval[debug.flags-removed] &^= bcFlagHasDebug
+		pos, removed = debug.end, removed+debug.end-debug.start
+	}
+	return append(retval, payload[pos:]...).seal(), nil
+}
+
+func (r *byteCodeReader) header() (ByteCodeHeader, error) {
+	var header ByteCodeHeader
+	header.Version = r.u8()
+	if r.err != nil {
+		return header, r.err
+	}
+	if header.Version != ByteCodeVersion {
+		return header, fmt.Errorf("%w: got %#x, expected %#x",
+			ErrByteCodeVersion, header.Version, ByteCodeVersion)
+	}
+	r.atoms = r.leb128()
+	for i := uint32(0); i < r.atoms && r.err == nil; i++ {
+		if name := r.string(); r.report {
+			r.names = append(r.names, name)
+		}
+	}
+	if r.err == nil && r.pos < len(r.data) {
+		header.Module = r.data[r.pos] == bcTagModule
+		header.Bundle = r.bundle()
+	}
+	return header, r.err
+}
+
+// Whether next value is an array starting with bundleMagic, reader is copied
+// so that its position is kept
+func (r byteCodeReader) bundle() bool {
+	r.report = true
+	if r.u8() != bcTagArray || r.leb128() == 0 || r.u8() != bcTagString {
+		return false
+	}
+	return r.string() == bundleMagic && r.err == nil
+}
+
+func (r *byteCodeReader) fail(format string, args ...any) {
+	if r.err == nil {
+		message := fmt.Sprintf(format, args...)
+		r.err = fmt.Errorf("%w: %s at %d", ErrInvalidByteCode, message, r.pos)
+	}
+}
+
+func (r *byteCodeReader) skip(size uint64) {
+	if r.err != nil {
+		return
+	}
+	if uint64(len(r.data)-r.pos) < size {
+		r.fail("unexpected end")
+		return
+	}
+	r.pos += int(size)
+}
+
+func (r *byteCodeReader) u8() uint8 {
+	if r.skip(1); r.err != nil {
+		return 0
+	}
+	return r.data[r.pos-1]
+}
+
+func (r *byteCodeReader) leb128() uint32 {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b := r.u8()
+		if r.err != nil {
+			return 0
+		}
+		value |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return value
+		}
+	}
+	r.fail("invalid leb128")
+	return 0
+}
+
+func (r *byteCodeReader) sleb128() int32 {
+	value := r.leb128()
+	return int32(value>>1) ^ -int32(value&1)
+}
+
+// Content is only decoded when reporting
+func (r *byteCodeReader) string() string {
+	length := r.leb128()
+	start := r.pos
+	if r.skip(uint64(length>>1) << (length & 1)); r.err != nil || !r.report {
+		return ""
+	}
+	data := r.data[start:r.pos]
+	if length&1 == 0 { // latin1
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	wide := make([]uint16, len(data)/2)
+	for i := range wide {
+		wide[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(wide))
+}
+
+func (r *byteCodeReader) atom() uint32 {
+	index := r.leb128()
+	// Odd values are tagged integers, smaller even values are builtin atoms
+	// which can't be checked without runtime
+	if index&1 == 0 && index>>1 >= jsAtomEnd && index>>1-jsAtomEnd >= r.atoms {
+		r.fail("invalid atom index %d", index>>1)
+	}
+	return index
+}
+
+func (r *byteCodeReader) value(depth int) {
+	if depth > bcMaxDepth {
+		r.fail("too deeply nested")
+	}
+	tag := r.u8()
+	if r.err != nil {
+		return
+	}
+	switch tag {
+	case bcTagNull, bcTagUndefined, bcTagBoolFalse, bcTagBoolTrue:
+	case bcTagInt32:
+		r.sleb128()
+	case bcTagFloat64:
+		r.skip(8)
+	case bcTagString:
+		r.string()
+	case bcTagFunctionByteCode:
+		r.function(depth)
+	case bcTagModule:
+		r.module(depth)
+	case bcTagObject:
+		count := r.leb128()
+		for i := uint32(0); i < count && r.err == nil; i++ {
+			r.atom()
+			r.value(depth + 1)
+		}
+	case bcTagArray, bcTagTemplateObject:
+		length := r.leb128()
+		for i := uint32(0); i < length && r.err == nil; i++ {
+			r.value(depth + 1)
+		}
+		if tag == bcTagTemplateObject {
+			r.value(depth + 1)
+		}
+	case bcTagTypedArray:
+		if r.u8() >= bcTypedArrayCount {
+			r.fail("invalid typed array")
+		}
+		r.leb128()
+		r.leb128()
+		r.value(depth + 1)
+	case bcTagArrayBuffer:
+		r.skip(uint64(r.leb128()))
+	case bcTagDate, bcTagObjectValue:
+		r.value(depth + 1)
+	case bcTagBigInt, bcTagBigFloat, bcTagBigDecimal:
+		r.bigNum(tag)
+	default:
+		// Shared array buffers and references are not allowed in ByteCode
+		r.pos--
+		r.fail("invalid tag %d", tag)
+	}
+}
+
+func (r *byteCodeReader) bigNum(tag uint8) {
+	// Zero, infinity and NaN have no mantissa
+	if exponent := r.sleb128() >> 1; exponent >= 0 && exponent <= 2 {
+		return
+	}
+	length := r.leb128()
+	if r.err == nil && length == 0 {
+		r.fail("invalid bignum length")
+	}
+	if tag == bcTagBigDecimal {
+		// Decimal digits are packed by two
+		r.skip((uint64(length) + 1) / 2)
+	} else {
+		r.skip(uint64(length))
+	}
+}
+
+func (r *byteCodeReader) function(depth int) {
+	var function functionSize
+	start := r.pos - 1
+	flagsPos := r.pos
+	flags := uint16(r.u8()) | uint16(r.u8())<<8
+	hasDebug := flags&(bcFlagHasDebug<<8) != 0
+	r.u8() // js_mode
+	function.name = r.atom()
+	for i := 0; i < 4; i++ {
+		r.leb128() // arg_count, var_count, defined_arg_count, stack_size
+	}
+	closureCount := r.leb128()
+	cpoolCount := r.leb128()
+	byteCodeLength := r.leb128()
+	localCount := r.leb128()
+	for i := uint32(0); i < localCount && r.err == nil; i++ {
+		r.atom()
+		r.leb128() // scope_level
+		r.leb128() // scope_next
+		r.u8()
+	}
+	for i := uint32(0); i < closureCount && r.err == nil; i++ {
+		r.atom()
+		r.leb128() // var_idx
+		r.u8()
+	}
+	r.skip(uint64(byteCodeLength))
+	function.ByteCode = int(byteCodeLength)
+	if hasDebug {
+		debugStart := r.pos
+		function.filename = r.atom()
+		function.Line = int(r.leb128())
+		function.Debug = int(r.leb128())
+		r.skip(uint64(function.Debug))
+		if r.strip {
+			r.debug = append(r.debug, debugInfo{flagsPos + 1, debugStart, r.pos})
+		}
+	}
+	// Nested functions are reported after their parent
+	index := len(r.functions)
+	if r.report {
+		r.functions = append(r.functions, function)
+	}
+	for i := uint32(0); i < cpoolCount && r.err == nil; i++ {
+		r.value(depth + 1)
+	}
+	if r.report {
+		r.functions[index].Size = r.pos - start
+	}
+}
+
+func (r *byteCodeReader) module(depth int) {
+	r.atom()
+	requires := r.leb128()
+	for i := uint32(0); i < requires && r.err == nil; i++ {
+		r.atom()
+	}
+	exports := r.leb128()
+	for i := uint32(0); i < exports && r.err == nil; i++ {
+		// JS_EXPORT_TYPE_LOCAL refers variable, others refer import
+		if r.u8() == 0 {
+			r.leb128()
+		} else {
+			r.leb128()
+			r.atom()
+		}
+		r.atom()
+	}
+	stars := r.leb128()
+	for i := uint32(0); i < stars && r.err == nil; i++ {
+		r.leb128()
+	}
+	imports := r.leb128()
+	for i := uint32(0); i < imports && r.err == nil; i++ {
+		r.leb128()
+		r.atom()
+		r.leb128()
+	}
+	r.u8() // has_tla
+	r.value(depth + 1)
+}
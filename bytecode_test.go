@@ -0,0 +1,211 @@
+package quickjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByteCode(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		byteCode, err := context.Compile(`
+			class Point { constructor(x) { this.x = x } }
+			const tag = (s, ...v) => s.raw.join('') + v.length
+			const big = 12345678901234567890n, float = 1.5
+			const re = /a+b/g, items = [1, 'two', { three: 3 }]
+			tag` + "`a${1}b`" + ` + new Point(big).x + float + re.source + items.length
+		`)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(ByteCodeVersion), byteCode[0])
+		assert.NoError(t, byteCode.Validate())
+		header, err := byteCode.Header()
+		assert.NoError(t, err)
+		assert.Equal(t, ByteCodeHeader{Version: ByteCodeVersion}, header)
+
+		assert.ErrorIs(t, byteCode[:1].Validate(), ErrInvalidByteCode)
+		for size := 0; size < len(byteCode); size++ {
+			truncated := byteCode[:size]
+			_, err = context.EvalBinary(truncated)
+			assert.ErrorIs(t, err, ErrInvalidByteCode)
+		}
+
+		outdated := append(ByteCode{}, byteCode...)
+		outdated[0]--
+		_, err = outdated.Header()
+		assert.ErrorIs(t, err, ErrByteCodeVersion)
+		_, err = context.EvalBinary(outdated)
+		assert.ErrorIs(t, err, ErrByteCodeVersion)
+
+		trailing := append(append(ByteCode{}, byteCode...), 0)
+		assert.ErrorIs(t, trailing.Validate(), ErrInvalidByteCode)
+		_, err = context.EvalBinary(trailing)
+		assert.ErrorIs(t, err, ErrInvalidByteCode)
+
+		value, err := context.EvalBinary(byteCode)
+		assert.NoError(t, err)
+		assert.Equal(t, "ab1123456789012345678901.5a+b3", value.String())
+
+		byteCode, err = context.CompileWithOptions("export default 1", CompileOptions{
+			EvalOptions: EvalOptions{Type: EvalModule},
+		})
+		assert.NoError(t, err)
+		header, err = byteCode.Header()
+		assert.NoError(t, err)
+		assert.True(t, header.Module)
+		assert.NoError(t, byteCode.Validate())
+		_, err = context.EvalModuleBinary(byteCode[:len(byteCode)-1])
+		assert.ErrorIs(t, err, ErrInvalidByteCode)
+	})
+}
+
+func TestCorruptedByteCode(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		byteCode, err := context.Compile("function f(a, b) { return a + b }; f(1, 2)")
+		assert.NoError(t, err)
+		for i := 1; i < len(byteCode); i++ { // First byte is version
+			for _, value := range []byte{0x7f, ^byteCode[i]} {
+				corrupted := append(ByteCode{}, byteCode...)
+				if corrupted[i] == value {
+					continue
+				}
+				corrupted[i] = value
+				assert.ErrorIs(t, corrupted.Validate(), ErrInvalidByteCode, i)
+				_, err = context.EvalBinary(corrupted)
+				assert.ErrorIs(t, err, ErrInvalidByteCode, i)
+				_, err = context.FunctionSizes(corrupted)
+				assert.ErrorIs(t, err, ErrInvalidByteCode, i)
+			}
+		}
+		value, err := context.EvalBinary(byteCode)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, value.ToNative())
+	})
+}
+
+func TestCompileStrip(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		code := `
+			function outer(value) {
+				throw new Error(value)
+			}
+			function unused() { return [1, 2].map(x => x * 2) }
+			outer('oops')`
+		options := CompileOptions{EvalOptions: EvalOptions{Filename: "compiled.js"}}
+		full, err := context.CompileWithOptions(code, options)
+		assert.NoError(t, err)
+		options.Strip = StripAll
+		stripped, err := context.CompileWithOptions(code, options)
+		assert.NoError(t, err)
+		assert.Less(t, len(stripped), len(full))
+		options.Strip = StripDebug
+		debugStripped, err := context.CompileWithOptions(code, options)
+		assert.NoError(t, err)
+		assert.Less(t, len(debugStripped), len(full))
+		assert.Greater(t, len(debugStripped), len(stripped))
+		_, err = context.EvalBinary(debugStripped)
+		assert.Equal(t, StackFrame{Function: "outer"}, err.(*Error).Stack[0])
+		sizes, err := context.FunctionSizes(debugStripped)
+		assert.NoError(t, err)
+		assert.Len(t, sizes, 4)
+		assert.Equal(t, 0, sizes[1].Debug)
+
+		// Variable names are only kept by StripDebug
+		code = "function f() { x; let x = 1 }; f()"
+		options.Strip = StripDebug
+		byteCode, err := context.CompileWithOptions(code, options)
+		assert.NoError(t, err)
+		_, err = context.EvalBinary(byteCode)
+		assert.Equal(t, "x is not initialized", err.(*Error).Message)
+		options.Strip = StripAll
+		byteCode, err = context.CompileWithOptions(code, options)
+		assert.NoError(t, err)
+		_, err = context.EvalBinary(byteCode)
+		assert.Equal(t, "lexical variable is not initialized", err.(*Error).Message)
+
+		_, err = context.EvalBinary(full)
+		frame := StackFrame{Function: "outer", File: "compiled.js", Line: 3}
+		assert.Equal(t, frame, err.(*Error).Stack[0])
+		_, err = context.EvalBinary(stripped)
+		assert.Equal(t, StackFrame{Function: "outer"}, err.(*Error).Stack[0])
+
+		sizes, err = context.FunctionSizes(full)
+		assert.NoError(t, err)
+		names := make([]string, len(sizes))
+		for i, size := range sizes {
+			names[i] = size.Name
+			assert.Equal(t, "compiled.js", size.Filename)
+			assert.Greater(t, size.ByteCode, 0)
+			assert.Greater(t, size.Size, size.ByteCode+size.Debug)
+		}
+		assert.Equal(t, []string{"<eval>", "outer", "unused", ""}, names)
+		assert.Equal(t, 2, sizes[1].Line)
+		assert.Greater(t, sizes[1].Debug, 0)
+		assert.Greater(t, sizes[2].Size, sizes[3].Size)
+
+		sizes, err = context.FunctionSizes(stripped)
+		assert.NoError(t, err)
+		assert.Len(t, sizes, 4)
+		assert.Equal(t, "outer", sizes[1].Name)
+		assert.Equal(t, "", sizes[1].Filename)
+		assert.Equal(t, 0, sizes[1].Debug)
+	})
+}
+
+// Constants mirrored in ffi.h are checked against values serialized by quickjs
+func TestByteCodeConstants(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		serialize := func(code string) *byteCodeReader {
+			value, err := context.Eval(code)
+			assert.NoError(t, err)
+			owned := value.Dup()
+			defer owned.Free()
+			data, err := context.writeByteCode(context.consume(owned))
+			assert.NoError(t, err)
+			reader := &byteCodeReader{data: data, report: true}
+			assert.Equal(t, uint8(ByteCodeVersion), reader.u8())
+			for i := reader.leb128(); i > 0; i-- {
+				reader.names = append(reader.names, reader.string())
+			}
+			return reader
+		}
+		tags := map[string]uint8{
+			"null":               bcTagNull,
+			"undefined":          bcTagUndefined,
+			"false":              bcTagBoolFalse,
+			"true":               bcTagBoolTrue,
+			"1":                  bcTagInt32,
+			"1.5":                bcTagFloat64,
+			"'text'":             bcTagString,
+			"({})":               bcTagObject,
+			"[]":                 bcTagArray,
+			"1n":                 bcTagBigInt,
+			"new Uint8Array(1)":  bcTagTypedArray,
+			"new ArrayBuffer(1)": bcTagArrayBuffer,
+			"new Date(0)":        bcTagDate,
+			"new Number(1)":      bcTagObjectValue,
+		}
+		for code, tag := range tags {
+			assert.Equal(t, tag, serialize(code).u8(), code)
+		}
+		byteCode, err := context.Compile("1")
+		assert.NoError(t, err)
+		reader := byteCodeReader{data: byteCode}
+		_, err = reader.header()
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(bcTagFunctionByteCode), reader.u8())
+
+		reader = *serialize("new Float64Array(1)")
+		reader.u8()
+		assert.Equal(t, uint8(bcTypedArrayCount-1), reader.u8()) // Last typed array
+		reader = *serialize("new Uint8ClampedArray(1)")
+		reader.u8()
+		assert.Equal(t, uint8(0), reader.u8())
+
+		// First atom not builtin is serialized as JS_ATOM_END
+		reader = *serialize("({ notBuiltin: 1 })")
+		assert.Equal(t, []string{"notBuiltin"}, reader.names)
+		assert.Equal(t, uint8(bcTagObject), reader.u8())
+		assert.Equal(t, uint32(1), reader.leb128())
+		assert.Equal(t, uint32(jsAtomEnd<<1), reader.leb128())
+	})
+}
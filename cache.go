@@ -0,0 +1,165 @@
+package quickjs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/qiuchengxuan/go-quickjs/libquickjs"
+)
+
+// Persistent storage of CompileCache entries
+type CacheBackend interface {
+	// Return nil ByteCode and nil error when key not found
+	Get(key string) (ByteCode, error)
+	Put(key string, byteCode ByteCode) error
+}
+
+// Cache backend storing each entry as file named by key with .jsc extension
+type DirCache struct{ Dir string }
+
+func (d DirCache) Get(key string) (ByteCode, error) {
+	data, err := os.ReadFile(filepath.Join(d.Dir, key+".jsc"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Entry is written to temporary file and renamed, so concurrent workers
+// never observe partial entry
+func (d DirCache) Put(key string, byteCode ByteCode) error {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(d.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(byteCode)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(d.Dir, key+".jsc"))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// Cache of compiled ByteCode keyed by hash of ByteCodeVersion, QuickJS release
+// and build flags, CompileOptions and source, so entries compiled by different
+// QuickJS version are never hit.
+// Safe for concurrent use by multiple contexts.
+type CompileCache struct {
+	backend      CacheBackend
+	errorHandler func(key string, err error)
+	mutex        sync.RWMutex
+	entries      map[string]ByteCode
+}
+
+// Backend is optional, entries are kept in memory only if nil
+func NewCompileCache(backend CacheBackend) *CompileCache {
+	return &CompileCache{backend: backend, entries: make(map[string]ByteCode)}
+}
+
+// Handle errors returned by backend, which are ignored if handler is nil.
+// Failing backend never fails Compile, entries are still cached in memory.
+// Must be called before the cache is used.
+func (c *CompileCache) OnBackendError(handler func(key string, err error)) {
+	c.errorHandler = handler
+}
+
+func (c *CompileCache) backendError(key string, err error) {
+	if c.errorHandler != nil {
+		c.errorHandler(key, err)
+	}
+}
+
+// Release and build flags of QuickJS, since ByteCode of the same
+// ByteCodeVersion may still differ between releases
+var engineVersion = func() string {
+	if libquickjs.BigNum {
+		return libquickjs.Version + "+bignum"
+	}
+	return libquickjs.Version
+}()
+
+func cacheKey(code string, options CompileOptions) string {
+	hash := sha256.New()
+	var header [12]byte
+	header[0] = ByteCodeVersion
+	header[1] = byte(options.Type)
+	if options.Strict {
+		header[2] = 1
+	}
+	header[3] = byte(options.Strip)
+	binary.LittleEndian.PutUint64(header[4:], uint64(options.Line))
+	hash.Write(header[:])
+	hash.Write([]byte(engineVersion + "\x00"))
+	hash.Write([]byte(options.Filename + "\x00"))
+	hash.Write([]byte(code))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Number of entries in memory
+func (c *CompileCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.entries)
+}
+
+// Same as Context.CompileWithOptions but return cached ByteCode if any.
+// Backend entries failing ByteCode.Validate, e.g. corrupted files, are
+// recompiled and replaced.
+func (c *CompileCache) Compile(
+	context *Context, code string, options ...CompileOptions,
+) (ByteCode, error) {
+	var option CompileOptions
+	if len(options) > 0 {
+		option = options[0]
+	}
+	key := cacheKey(code, option)
+
+	c.mutex.RLock()
+	byteCode, ok := c.entries[key]
+	c.mutex.RUnlock()
+	if ok {
+		return byteCode, nil
+	}
+
+	if c.backend != nil {
+		byteCode, err := c.backend.Get(key)
+		if err != nil {
+			c.backendError(key, err)
+		} else if byteCode != nil && byteCode.Validate() == nil {
+			c.store(key, byteCode)
+			return byteCode, nil
+		}
+	}
+
+	byteCode, err := context.CompileWithOptions(code, option)
+	if err != nil {
+		return nil, err
+	}
+	if c.backend != nil {
+		if err := c.backend.Put(key, byteCode); err != nil {
+			c.backendError(key, err)
+		}
+	}
+	c.store(key, byteCode)
+	return byteCode, nil
+}
+
+func (c *CompileCache) store(key string, byteCode ByteCode) {
+	c.mutex.Lock()
+	c.entries[key] = byteCode
+	c.mutex.Unlock()
+}
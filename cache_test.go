@@ -0,0 +1,114 @@
+package quickjs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiuchengxuan/go-quickjs/libquickjs"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingBackend struct {
+	DirCache
+	gets, puts int
+}
+
+func (c *countingBackend) Get(key string) (ByteCode, error) {
+	c.gets++
+	return c.DirCache.Get(key)
+}
+
+func (c *countingBackend) Put(key string, byteCode ByteCode) error {
+	c.puts++
+	return c.DirCache.Put(key, byteCode)
+}
+
+func TestCompileCache(t *testing.T) {
+	backend := &countingBackend{DirCache: DirCache{Dir: t.TempDir()}}
+	NewRuntime().NewContext().With(func(context *Context) {
+		cache := NewCompileCache(backend)
+		byteCode, err := cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		again, err := cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, byteCode, again)
+		assert.Equal(t, 1, backend.gets)
+		assert.Equal(t, 1, backend.puts)
+
+		other := EvalOptions{Filename: "other.js"}
+		_, err = cache.Compile(context, "1 + 1", CompileOptions{EvalOptions: other})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cache.Len())
+		_, err = cache.Compile(context, "1 + 1", CompileOptions{Strip: StripDebug})
+		assert.NoError(t, err)
+		_, err = cache.Compile(context, "1 + 1", CompileOptions{Strip: StripAll})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, cache.Len())
+
+		_, err = cache.Compile(context, "1 +")
+		assert.Error(t, err)
+		assert.Equal(t, 4, cache.Len())
+
+		cache = NewCompileCache(backend)
+		again, err = cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, byteCode, again)
+		assert.Equal(t, 4, backend.puts)
+
+		// Entry compiled by other engine version is replaced
+		files, _ := filepath.Glob(filepath.Join(backend.Dir, "*.jsc"))
+		for _, file := range files {
+			assert.NoError(t, os.WriteFile(file, []byte{ByteCodeVersion + 1, 0}, 0o644))
+		}
+		cache = NewCompileCache(backend)
+		again, err = cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, byteCode, again)
+		assert.Equal(t, 5, backend.puts)
+		value, err := context.EvalBinary(again)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value.ToNative())
+
+		// Corrupted entry is replaced as well
+		for _, file := range files {
+			data, _ := os.ReadFile(file)
+			data[len(data)/2] ^= 0xff
+			assert.NoError(t, os.WriteFile(file, data, 0o644))
+		}
+		cache = NewCompileCache(backend)
+		again, err = cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, byteCode, again)
+		assert.Equal(t, 6, backend.puts)
+	})
+}
+
+func TestCompileCacheBackendError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o644))
+	NewRuntime().NewContext().With(func(context *Context) {
+		cache := NewCompileCache(DirCache{Dir: filepath.Join(file, "cache")})
+		var errs []error
+		cache.OnBackendError(func(key string, err error) { errs = append(errs, err) })
+		byteCode, err := cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		assert.Len(t, errs, 2) // Both Get and Put fail
+		assert.Equal(t, 1, cache.Len())
+		again, err := cache.Compile(context, "1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, byteCode, again)
+		assert.Len(t, errs, 2)
+		value, err := context.EvalBinary(byteCode)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value.ToNative())
+	})
+}
+
+func TestCacheKeyEngine(t *testing.T) {
+	assert.Equal(t, libquickjs.Version+"+bignum", engineVersion)
+	key := cacheKey("1 + 1", CompileOptions{})
+	defer func(version string) { engineVersion = version }(engineVersion)
+	engineVersion = "2021-03-27"
+	assert.NotEqual(t, key, cacheKey("1 + 1", CompileOptions{}))
+}
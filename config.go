@@ -1,8 +1,13 @@
 package quickjs
 
 type Config struct {
-	MaxStackSize int  // Use 0 to disable maximum stack size check
-	ManualFree   bool // Disable runtime and context finalizer and free quickjs manually
+	MaxStackSize int              // Use 0 to disable maximum stack size check
+	MemoryLimit  int              // Maximum bytes allocated by runtime, 0 means unlimited
+	GCThreshold  int              // Allocated bytes triggering next GC, 0 means quickjs default
+	ManualFree   bool             // Disable runtime and context finalizer and free quickjs manually
+	Debug        bool             // Check leaks on Context.Free and Runtime.Free
+	LeakHandler  func(LeakReport) // Called on leaks in debug mode, leaks are logged if nil
+	Repanic      bool             // Panic again once javascript is unwound from Go callback panic
 }
 
 func DefaultConfig() Config {
@@ -3,15 +3,26 @@ package quickjs
 //#include "ffi.h"
 import "C"
 import (
+	"errors"
+	"fmt"
 	"reflect"
 	"runtime"
 	"sync/atomic"
 	"unsafe"
 )
 
+// Stored as context opaque for callbacks providing only JSContext
+type contextData struct{ context *Context }
+
+func getContext(ctx *C.JSContext) *Context {
+	return (*contextData)(C.JS_GetContextOpaque(ctx)).context
+}
+
 type Context struct {
 	runtime          *Runtime
 	raw              *C.JSContext
+	compiler         *C.JSContext
+	data             unsafe.Pointer // *contextData
 	global           C.JSValue
 	evalRet          C.JSValue
 	goObjectProto    C.JSValueConst
@@ -20,6 +31,14 @@ type Context struct {
 	goValues         map[uintptr]any
 	objectKinds      map[C.JSValue]ObjectKind
 	protoClasses     map[reflect.Type]C.JSValueConst
+	modules          map[*C.JSModuleDef]map[string]any
+	nativeModules    []string
+	resolved         map[[2]string]string // Module resolutions of bundle being loaded
+	bundle           *bundle              // Dependencies recorded by CompileBundle
+	handles          map[C.JSValue]int    // Reference count owned by Value.Dup
+	scope            *Scope               // Innermost scope
+	eventLoop        *EventLoop
+	errorAtom        C.JSAtom // Symbol by which error object holds Go error
 	free             atomic.Bool
 }
 
@@ -35,7 +54,7 @@ func (c *Context) goObject(value any, proto jsValCst, classID classID, flags Obj
 
 func (c *Context) goIndexCall(value int) C.JSValue {
 	jsObject := C.JS_NewObjectProtoClass(c.raw, c.goIndexCallProto, c.runtime.goIndexCall)
-	C.JS_SetOpaque(jsObject, unsafe.Pointer(uintptr(value)))
+	C.JS_SetOpaqueInt(jsObject, C.intptr_t(value))
 	return jsObject
 }
 
@@ -43,17 +62,56 @@ func (c *Context) GlobalObject() GlobalObject {
 	return GlobalObject{Value{c, c.global}.Object()}
 }
 
-func (c *Context) Compile(code string) (ByteCode, error) {
-	codePtr := strPtr(code + "\x00")
-	filename := "<input>\x00"
-	flags := C.int(C.JS_EVAL_TYPE_GLOBAL | C.JS_EVAL_FLAG_COMPILE_ONLY)
-	if C.JS_DetectModule(codePtr, strlen(code)) != 0 {
-		flags |= C.JS_EVAL_TYPE_MODULE
+// Evaluate code in this context, if eval intrinsic is disabled,
+// code is compiled by compiler context then loaded into this context.
+func (c *Context) jsEval(code, filename string, line int, flags C.int) C.JSValue {
+	codePtr, filenamePtr, lineNum := strPtr(code+"\x00"), strPtr(filename+"\x00"), C.int(line)
+	if c.compiler == nil {
+		return C.JS_EvalLine(c.raw, codePtr, strlen(code), filenamePtr, lineNum, flags)
 	}
-	jsValue := C.JS_Eval(c.raw, codePtr, strlen(code), strPtr(filename), flags)
-	if err := c.checkException(jsValue); err != nil {
-		return nil, err
+	compileFlags := flags | C.JS_EVAL_FLAG_COMPILE_ONLY
+	compiled := C.JS_EvalLine(c.compiler, codePtr, strlen(code), filenamePtr, lineNum, compileFlags)
+	if C.JS_IsException(compiled) == 1 {
+		// Contexts share the runtime, so exception is moved as is
+		return C.JS_Throw(c.raw, C.JS_GetException(c.compiler))
 	}
+	var size C.size_t
+	pointer := C.JS_WriteObject(c.compiler, &size, compiled, C.JS_WRITE_OBJ_BYTECODE)
+	C.JS_FreeValue(c.compiler, compiled)
+	if pointer == nil {
+		return C.JS_Throw(c.raw, C.JS_GetException(c.compiler))
+	}
+	object := C.JS_ReadObject(c.raw, pointer, size, C.JS_READ_OBJ_BYTECODE)
+	C.js_free(c.compiler, unsafe.Pointer(pointer))
+	if C.JS_IsException(object) == 1 {
+		return object
+	}
+	if C.JS_IsModule(object) == 1 && C.JS_ResolveModule(c.raw, object) < 0 {
+		C.JS_FreeValue(c.raw, object)
+		return C.JS_Exception()
+	}
+	if flags&C.JS_EVAL_FLAG_COMPILE_ONLY != 0 {
+		return object
+	}
+	return C.JS_EvalFunction(c.raw, object)
+}
+
+// Release values owned by Value.Dup, return number of object handles
+func (c *Context) releaseHandles() int {
+	handles := 0
+	for handle, count := range c.handles {
+		if C.JS_ValueTag(handle) < 0 { // Primitive values are never leaked
+			handles += count
+		}
+		for ; count > 0; count-- {
+			C.JS_FreeValue(c.raw, handle)
+		}
+	}
+	clear(c.handles)
+	return handles
+}
+
+func (c *Context) writeByteCode(jsValue C.JSValue) (ByteCode, error) {
 	var size C.size_t
 	pointer := C.JS_WriteObject(c.raw, &size, jsValue, C.JS_WRITE_OBJ_BYTECODE)
 	C.JS_FreeValue(c.raw, jsValue)
@@ -62,37 +120,63 @@ func (c *Context) Compile(code string) (ByteCode, error) {
 	}
 	byteCode := C.GoBytes(unsafe.Pointer(pointer), C.int(size))
 	C.js_free(c.raw, unsafe.Pointer(pointer))
-	return byteCode, nil
+	return ByteCode(byteCode).seal(), nil
 }
 
-func (c *Context) eval(code string) (C.JSValue, error) {
-	codePtr := strPtr(code + "\x00")
-	filename := "<input>\x00"
-	flags := C.int(C.JS_EVAL_TYPE_GLOBAL)
-	if C.JS_DetectModule(codePtr, strlen(code)) != 0 {
-		flags |= C.JS_EVAL_TYPE_MODULE
+// Validate and deserialize ByteCode, entry module is returned for bundle
+func (c *Context) readByteCode(byteCode ByteCode) (C.JSValue, error) {
+	payload, err := byteCode.payload()
+	if err != nil {
+		return C.JS_Exception(), err
+	}
+	flags := C.int(C.JS_READ_OBJ_BYTECODE)
+	object := C.JS_ReadObject(c.raw, bytesPtr(payload), C.size_t(len(payload)), flags)
+	if C.JS_IsException(object) == 1 {
+		err := c.getException()
+		if !errors.Is(err, ErrOutOfMemory) {
+			err = fmt.Errorf("%w: %w", ErrInvalidByteCode, err)
+		}
+		return object, err
 	}
-	jsValue := C.JS_Eval(c.raw, codePtr, strlen(code), strPtr(filename), flags)
-	if err := c.checkException(jsValue); err != nil {
-		return null, err
+	if header, _ := byteCode.Header(); header.Bundle {
+		return c.loadBundle(object)
 	}
-	return jsValue, nil
+	return object, nil
+}
+
+// Same as CompileWithOptions with default options
+func (c *Context) Compile(code string) (ByteCode, error) {
+	return c.CompileWithOptions(code, CompileOptions{})
 }
 
-// Return value must be consumed immediately before next Eval or EvalBinary
+// Return value must be consumed before next Eval or EvalBinary, or retained by Dup
 func (c *Context) Eval(code string) (Value, error) {
-	C.JS_FreeValue(c.raw, c.evalRet)
-	value, err := c.eval(code)
-	c.evalRet = value
-	return Value{c, value}, err
+	return c.EvalWithOptions(code, EvalOptions{})
 }
 
-// Return value must be consumed immediately before next Eval or EvalBinary
+// Return value must be consumed before next Eval or EvalBinary, or retained by Dup
 func (c *Context) EvalBinary(byteCode ByteCode) (Value, error) {
-	flags := C.int(C.JS_READ_OBJ_BYTECODE)
-	object := C.JS_ReadObject(c.raw, bytesPtr(byteCode), C.size_t(len(byteCode)), flags)
-	retval := c.assert(C.JS_EvalFunction(c.raw, c.assert(object)))
+	object, err := c.readByteCode(byteCode)
+	if err != nil {
+		return Value{c, null}, err
+	}
+	isModule := C.JS_IsModule(object) == 1
+	if isModule && C.JS_ResolveModule(c.raw, object) < 0 {
+		C.JS_FreeValue(c.raw, object)
+		return Value{c, null}, c.getException()
+	}
 	C.JS_FreeValue(c.raw, c.evalRet)
+	c.evalRet = null // Evaluation may panic with Config.Repanic
+	retval := C.JS_EvalFunction(c.raw, object)
+	if err := c.checkException(retval); err != nil {
+		return Value{c, null}, err
+	}
+	if isModule {
+		if err := c.checkPromise(retval); err != nil {
+			C.JS_FreeValue(c.raw, retval)
+			return Value{c, null}, err
+		}
+	}
 	c.evalRet = retval
 	return Value{c, retval}, nil
 }
@@ -102,12 +186,44 @@ func (c *Context) Free() {
 	if c.free.Swap(true) {
 		return
 	}
+	// Rejections not yet reported would never be, hand them to handler
+	// while context is still intact
+	rejections := c.runtime.rejections[:0]
+	for _, rejection := range c.runtime.rejections {
+		if rejection.context != c {
+			rejections = append(rejections, rejection)
+			continue
+		}
+		if c.runtime.onRejection != nil {
+			c.runtime.onRejection(c, Value{c, rejection.promise}, Value{c, rejection.reason})
+		}
+		rejection.free()
+	}
+	c.runtime.rejections = rejections
+	handles := c.releaseHandles()
+	if c.eventLoop != nil {
+		c.eventLoop.release()
+	}
+	for call := range c.runtime.asyncCalls {
+		if call.context == c {
+			call.free()
+		}
+	}
+	C.JS_TakeOutOfMemoryError(c.runtime.raw, null) // Recorded error may belong to this context
+	C.JS_FreeAtom(c.raw, c.errorAtom)
 	C.JS_FreeValue(c.raw, c.global)
 	C.JS_FreeValue(c.raw, c.evalRet)
 	for _, proto := range c.protoClasses {
 		C.JS_FreeValue(c.raw, proto)
 	}
 	C.JS_FreeContext(c.raw)
+	if c.compiler != nil {
+		C.JS_FreeContext(c.compiler)
+	}
+	C.free(c.data)
+	if c.runtime.debug {
+		c.checkLeaks(handles)
+	}
 	c.runtime.Free()
 }
 
@@ -115,8 +231,10 @@ type ContextGuard struct{ context *Context }
 
 // Manipulate Context with os thread locked
 func (g ContextGuard) With(fn func(*Context)) {
-	// Reason unknown, without locking os thread will cause quickjs throw strange exception
+	// quickjs checks stack overflow against stack top of the os thread,
+	// which changes whenever goroutine is scheduled to another os thread
 	runtime.LockOSThread()
+	C.JS_UpdateStackTop(g.context.runtime.raw)
 	fn(g.context)
 	runtime.UnlockOSThread()
 }
@@ -126,18 +244,26 @@ func (g ContextGuard) Unwrap() *Context { return g.context }
 
 func (g ContextGuard) Free() { g.context.Free() }
 
+// Create context with all intrinsics including bignum extensions
 func (r *Runtime) NewContext() ContextGuard {
+	return r.NewContextWithOptions(ContextOptions{Intrinsics: IntrinsicAll})
+}
+
+func (r *Runtime) NewContextWithOptions(options ContextOptions) ContextGuard {
 	r.refCount.Add(1)
 	C.js_std_init_handlers(r.raw)
 
-	jsContext := C.JS_NewContext(r.raw)
-	C.JS_AddIntrinsicBigFloat(jsContext)
-	C.JS_AddIntrinsicBigDecimal(jsContext)
-	C.JS_AddIntrinsicOperators(jsContext)
-	C.JS_EnableBignumExt(jsContext, C.int(1))
-
+	jsContext := newRawContext(r.raw, options.Intrinsics)
 	object := C.JS_GetGlobalObject(jsContext)
 	context := &Context{runtime: r, raw: jsContext, global: object}
+	context.data = C.malloc(C.size_t(unsafe.Sizeof(contextData{})))
+	*(*contextData)(context.data) = contextData{context}
+	C.JS_SetContextOpaque(jsContext, context.data)
+	if options.Intrinsics&IntrinsicEval == 0 {
+		context.compiler = newCompiler(r.raw, options.Intrinsics)
+		C.JS_SetContextOpaque(context.compiler, context.data)
+		context.GlobalObject().DeleteProperty("eval")
+	}
 	context.goObjectProto = C.JS_NewObject(jsContext)
 	C.JS_SetClassProto(jsContext, r.goObject, context.goObjectProto)
 	context.goFuncProto = C.JS_NewObject(jsContext)
@@ -146,12 +272,19 @@ func (r *Runtime) NewContext() ContextGuard {
 	C.JS_SetClassProto(jsContext, r.goIndexCall, context.goIndexCallProto)
 	objectKinds := make(map[C.JSValue]ObjectKind, KindMax)
 	for i, name := range builtinKinds {
-		jsValue, _ := context.GlobalObject().GetProperty(name)
-		objectKinds[jsValue.raw] = ObjectKind(i + 1)
+		// Constructors are kept alive by global object
+		jsValue := context.GlobalObject().getProperty(name)
+		if C.JS_IsObject(jsValue) == 1 {
+			objectKinds[jsValue] = ObjectKind(i + 1)
+		}
+		C.JS_FreeValue(jsContext, jsValue)
 	}
 	context.goValues = make(map[uintptr]any)
 	context.objectKinds = objectKinds
 	context.protoClasses = make(map[reflect.Type]C.JSValue)
+	context.modules = make(map[*C.JSModuleDef]map[string]any)
+	context.errorAtom = context.newSymbol(goErrorKey)
+	context.handles = make(map[C.JSValue]int)
 	if !r.manualFree {
 		runtime.SetFinalizer(context, func(c *Context) { c.Free() })
 	}
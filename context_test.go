@@ -1,6 +1,7 @@
 package quickjs
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,3 +34,49 @@ func TestCompile(t *testing.T) {
 	guard.Free()
 	runtime.Free()
 }
+
+func TestMemoryLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.MemoryLimit = 4 << 20
+	config.GCThreshold = 1 << 20
+	NewRuntime(config).NewContext().With(func(context *Context) {
+		// Released by finally since compiling anything may fail when exhausted
+		code := `let a = []; try { for (;;) a.push(new Array(1024).fill(0)) } finally { a = undefined }`
+		_, err := context.Eval(code)
+		assert.ErrorIs(t, err, ErrOutOfMemory)
+
+		context.runtime.RunGC()
+		value, err := context.Eval("1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value.ToNative())
+	})
+}
+
+func TestOutOfMemory(t *testing.T) {
+	config := DefaultConfig()
+	config.MemoryLimit = 4 << 20
+	NewRuntime(config).NewContext().With(func(context *Context) {
+		_, err := context.Eval(`new ArrayBuffer(8 << 20)`)
+		assert.ErrorIs(t, err, ErrOutOfMemory)
+		_, err = context.Eval(`"x".repeat(16 << 20)`)
+		assert.ErrorIs(t, err, ErrOutOfMemory)
+
+		// Only errors thrown by allocator are reported as ErrOutOfMemory
+		_, err = context.Eval(`throw new InternalError("out of memory")`)
+		assert.NotErrorIs(t, err, ErrOutOfMemory)
+		assert.Equal(t, "InternalError: out of memory", err.Error())
+		_, err = context.Eval(`
+			let caught
+			try { new ArrayBuffer(8 << 20) } catch (e) { caught = e }
+			throw caught`)
+		assert.ErrorIs(t, err, ErrOutOfMemory)
+		_, err = context.Eval(`try { new ArrayBuffer(8 << 20) } catch {} throw null`)
+		assert.NotErrorIs(t, err, ErrOutOfMemory)
+
+		// Compiler runs out of memory as well
+		_, err = context.Eval(`var a = []; try { for (;;) a.push(new Array(1024)) } catch {}`)
+		assert.NoError(t, err)
+		_, err = context.Eval(strings.Repeat("a = 0;", 4096))
+		assert.ErrorIs(t, err, ErrOutOfMemory)
+	})
+}
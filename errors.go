@@ -3,20 +3,288 @@ package quickjs
 //#include "ffi.h"
 import "C"
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"unsafe"
 )
 
+var (
+	// Returned when evaluation is aborted by context cancellation or deadline
+	ErrInterrupted = errors.New("interrupted")
+	// Returned when runtime exceeds Config.MemoryLimit
+	ErrOutOfMemory = errors.New("out of memory")
+	// Returned when metered evaluation consumes more units than budget
+	ErrBudgetExceeded = errors.New("budget exceeded")
+	// Returned when scheduling work to a closed Executor
+	ErrExecutorClosed = errors.New("executor closed")
+	// Returned when taking context from a closed Pool
+	ErrPoolClosed = errors.New("pool closed")
+	// Promise returned by async function is rejected with it if AsyncFunc
+	// returns nil channel
+	ErrNilChannel = errors.New("nil result channel")
+)
+
+// Cause chain deeper than this is truncated, e.g. error being its own cause
+const maxErrorCause = 16
+
+// Values held by error deeper or longer than these are truncated
+const (
+	maxErrorValueDepth  = 16
+	maxErrorValueLength = 1 << 12
+)
+
+// Description of symbol by which error object holds Go error
+const goErrorKey = "goError"
+
+// Frame of javascript stack trace, quickjs provides no column
+type StackFrame struct {
+	Function string // Empty for location of syntax error
+	File     string // Empty for stripped bytecode
+	Line     int    // Zero if unknown
+	Column   int    // Zero if unknown
+	Native   bool   // Function implemented natively, e.g. Array.prototype.map
+}
+
+func (f StackFrame) String() string {
+	location := f.File
+	switch {
+	case f.Native:
+		location = "native"
+	case f.Line > 0 && f.Column > 0:
+		location = fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	case f.Line > 0:
+		location = fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	switch {
+	case f.Function == "":
+		return location
+	case location == "":
+		return f.Function
+	default:
+		return f.Function + " (" + location + ")"
+	}
+}
+
+// Exception thrown by javascript
+type Error struct {
+	Name    string // e.g. TypeError or RangeError, empty if thrown value is not an Error
+	Message string
+	// Native form of thrown value, e.g. map[string]any for `throw {code: 1}`,
+	// or own properties such as code of an Error except message, stack and cause.
+	// Only data properties are read, cyclic references are NotNative
+	Value any
+	// Deprecated: same as Error(), kept for compatibility. Use CauseError
+	// for error.cause
+	Cause      string
+	CauseError error // Converted from error.cause
+	Stack      []StackFrame
+	RawStack   string // Stack text as is, including lines Stack can't parse
+	Err        error  // Go error returned by Func and propagated through javascript
+}
+
+func (e Error) Error() string {
+	switch {
+	case e.Name == "":
+		return e.Message
+	case e.Message == "":
+		return e.Name
+	default:
+		return e.Name + ": " + e.Message
+	}
+}
+
+// Error converted from error.cause
+func (e Error) Unwrap() error { return e.CauseError }
+
+// Match Go error by errors.Is, cause is matched through Unwrap
+func (e Error) Is(target error) bool { return e.Err != nil && errors.Is(e.Err, target) }
+
+// Match Go error by errors.As, cause is matched through Unwrap
+func (e Error) As(target any) bool { return e.Err != nil && errors.As(e.Err, target) }
+
+// Parse location such as file:line or file:line:column
+func parseLocation(location string) (file string, line, column int, ok bool) {
+	file, last, found := cutLast(location, ":")
+	lastNumber, err := strconv.Atoi(last)
+	if !found || err != nil {
+		return location, 0, 0, false
+	}
+	prefix, previous, found := cutLast(file, ":")
+	if number, err := strconv.Atoi(previous); found && err == nil {
+		return prefix, number, lastNumber, true
+	}
+	return file, lastNumber, 0, true
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Parse stack in form of "    at function (file:line)" per line
+func parseStack(stack string) []StackFrame {
+	var frames []StackFrame
+	for _, text := range strings.Split(stack, "\n") {
+		text, ok := strings.CutPrefix(strings.TrimSpace(text), "at ")
+		if !ok {
+			continue
+		}
+		var frame StackFrame
+		if fn, location, ok := strings.Cut(text, " ("); ok && strings.HasSuffix(location, ")") {
+			frame.Function, location = fn, strings.TrimSuffix(location, ")")
+			if location == "native" {
+				frame.Native = true
+			} else {
+				frame.File, frame.Line, frame.Column, _ = parseLocation(location)
+			}
+		} else if file, line, column, ok := parseLocation(text); ok {
+			frame.File, frame.Line, frame.Column = file, line, column
+		} else {
+			frame.Function = text // Stripped bytecode has no location
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
 func (c *Context) getException() error {
 	value := Value{c, C.JS_GetException(c.raw)}
-	cause := value.String()
-	stack, _ := value.Object().GetProperty("stack")
-	if stack.Type() == TypeUndefined {
-		return &Error{Cause: cause}
+	if err := c.runtime.interrupted; err != nil {
+		c.runtime.interrupted = nil
+		value.free()
+		return err
 	}
-	err := &Error{Cause: cause, Stack: stack.String()}
-	C.JS_FreeValue(c.raw, value.raw)
-	return err
+	if panicked := c.runtime.panicked; panicked != nil {
+		c.runtime.panicked = nil
+		value.free()
+		panic(panicked)
+	}
+	return c.toError(value)
+}
+
+// Convert thrown or rejected value to error, value is released
+func (c *Context) toError(value Value) error {
+	return c.newError(value, 0)
+}
+
+func (c *Context) newError(value Value, depth int) error {
+	defer value.free()
+	if c.isOutOfMemory(value.raw) {
+		return ErrOutOfMemory
+	}
+	visited := map[unsafe.Pointer]bool{}
+	if C.JS_IsError(c.raw, value.raw) == 0 {
+		message := value.String()
+		native := c.dataToNative(value.raw, 0, visited)
+		return &Error{Message: message, Cause: message, Value: native}
+	}
+	// Only data properties are read, script must not run during conversion
+	object, retval := value.Object(), &Error{Err: c.goError(value.raw)}
+	visited[C.JS_ValuePtr(value.raw)] = true
+	var properties map[string]any
+	// Symbol keys are skipped, including errorAtom holding Go error
+	object.ownProperties(flagStringMask, func(key C.JSAtom) {
+		name := atom{c, key}.String()
+		property, ok := object.ownDataProperty(key)
+		if !ok {
+			return
+		}
+		switch name {
+		case "message":
+			retval.Message = Value{c, property}.String()
+		case "stack":
+			retval.RawStack = Value{c, property}.String()
+			retval.Stack = parseStack(retval.RawStack)
+		case "fileName", "lineNumber": // Location of syntax error, also in stack
+		case "cause":
+			if depth < maxErrorCause {
+				retval.CauseError = c.newError(Value{c, property}, depth+1)
+				return // Released by newError
+			}
+		default:
+			if properties == nil {
+				properties = make(map[string]any)
+			}
+			properties[name] = c.dataToNative(property, 1, visited)
+		}
+		C.JS_FreeValue(c.raw, property)
+	})
+	nameAtom := C.JS_NewAtom(c.raw, strPtr("name\x00"))
+	if name, ok := object.dataProperty(nameAtom); ok {
+		if C.JS_IsString(name) == 1 {
+			retval.Name = Value{c, name}.String()
+		}
+		C.JS_FreeValue(c.raw, name)
+	}
+	C.JS_FreeAtom(c.raw, nameAtom)
+	if properties != nil {
+		retval.Value = properties
+	}
+	retval.Cause = retval.Error()
+	return retval
+}
+
+// Convert value held by error like ToNative, but only data properties of
+// plain objects, arrays and errors are read so that no script runs. Other
+// objects, cyclic references and values nested too deep are NotNative
+func (c *Context) dataToNative(value C.JSValue, depth int, visited map[unsafe.Pointer]bool) any {
+	if C.JS_IsObject(value) == 0 {
+		return Value{c, value}.ToNative()
+	}
+	pointer, classID := C.JS_ValuePtr(value), C.JS_GetClassID(value)
+	switch {
+	case visited[pointer]:
+		return NotNative{"[Circular]"}
+	case classID == c.runtime.goObject:
+		return getObjectData(value).value
+	case depth >= maxErrorValueDepth:
+		return NotNative{"[Object]"}
+	case classID == C.JS_CLASS_OBJECT || classID == C.JS_CLASS_ARRAY:
+	case classID == C.JS_CLASS_ERROR:
+	default:
+		return NotNative{"[Object]"}
+	}
+	visited[pointer] = true
+	defer delete(visited, pointer)
+	object := Value{c, value}.Object()
+	if classID == C.JS_CLASS_ARRAY {
+		return c.dataArrayToNative(object, depth, visited)
+	}
+	retval := make(map[string]any)
+	object.ownProperties(flagStringMask|flagEnumOnly, func(key C.JSAtom) {
+		if property, ok := object.ownDataProperty(key); ok {
+			retval[atom{c, key}.String()] = c.dataToNative(property, depth+1, visited)
+			C.JS_FreeValue(c.raw, property)
+		}
+	})
+	return retval
+}
+
+func (c *Context) dataArrayToNative(array Object, depth int, visited map[unsafe.Pointer]bool) any {
+	length, _ := array.ownDataProperty(C.JS_ATOM_length)
+	size, _ := c.consumeNative(length).(int)
+	retval := make([]any, min(size, maxErrorValueLength))
+	for i := range retval {
+		key := C.JS_NewAtomUInt32(c.raw, C.uint32_t(i))
+		if element, ok := array.ownDataProperty(key); ok {
+			retval[i] = c.dataToNative(element, depth+1, visited)
+			C.JS_FreeValue(c.raw, element)
+		} else {
+			retval[i] = Undefined
+		}
+		C.JS_FreeAtom(c.raw, key)
+	}
+	return retval
+}
+
+// Error thrown by quickjs when allocation fails is recorded by runtime,
+// so that it can't be confused with error thrown by script
+func (c *Context) isOutOfMemory(value C.JSValue) bool {
+	return C.JS_TakeOutOfMemoryError(c.runtime.raw, value) != 0
 }
 
 func (c *Context) checkException(value C.JSValue) error {
@@ -33,9 +301,112 @@ func (c *Context) assert(value C.JSValue) C.JSValue {
 	return value
 }
 
+// Error returned by Func to be thrown as is, created by TypeError, RangeError,
+// SyntaxError, ReferenceError or Throw
+type throwable struct {
+	class   C.ErrorClass
+	message string
+	value   any // Thrown value if created by Throw
+	thrown  bool
+}
+
+func (t *throwable) Error() string {
+	if t.thrown {
+		return fmt.Sprint(t.value)
+	}
+	return t.message
+}
+
+func newThrowable(class C.ErrorClass, format string, args []any) error {
+	return &throwable{class: class, message: fmt.Sprintf(format, args...)}
+}
+
+// Returned by Func to throw TypeError, e.g. for invalid argument
+func TypeError(format string, args ...any) error {
+	return newThrowable(C.TYPE_ERROR, format, args)
+}
+
+// Returned by Func to throw RangeError
+func RangeError(format string, args ...any) error {
+	return newThrowable(C.RANGE_ERROR, format, args)
+}
+
+// Returned by Func to throw SyntaxError
+func SyntaxError(format string, args ...any) error {
+	return newThrowable(C.SYNTAX_ERROR, format, args)
+}
+
+// Returned by Func to throw ReferenceError
+func ReferenceError(format string, args ...any) error {
+	return newThrowable(C.REFERENCE_ERROR, format, args)
+}
+
+// Returned by Func to throw value converted with ToValue, e.g. Throw(map[string]any{"code": 1})
+func Throw(value any) error {
+	return &throwable{value: value, thrown: true}
+}
+
+// Throw err to javascript, err returned by TypeError or alike keeps its class,
+// others are thrown as InternalError. Thrown error object holds err by hidden
+// symbol, so that err is returned as Error.Err if exception is not caught
+func (c *Context) throw(err error) C.JSValue {
+	var throwable *throwable
+	switch {
+	case !errors.As(err, &throwable):
+		c.ThrowInternalError("%s", err)
+	case throwable.thrown:
+		return C.JS_Throw(c.raw, c.toValue(throwable.value))
+	default:
+		C.ThrowError(c.raw, throwable.class, strPtr(err.Error()+"\x00")) // Keep wrapping
+	}
+	exception := C.JS_GetException(c.raw)
+	if C.JS_IsObject(exception) == 1 { // Not the case if out of memory
+		goError := c.goObject(err, c.goObjectProto, c.runtime.goObject, 0)
+		C.JS_DefinePropertyValue(c.raw, exception, c.errorAtom, goError, 0)
+	}
+	return C.JS_Throw(c.raw, exception)
+}
+
+// Atom of a new unique symbol with description
+func (c *Context) newSymbol(description string) C.JSAtom {
+	symbol := c.GlobalObject().getProperty("Symbol")
+	argument := c.toValue(description)
+	value := Value{c, symbol}.Object().call(null, 1, &argument)
+	retval := C.JS_ValueToAtom(c.raw, value)
+	C.JS_FreeValue(c.raw, symbol)
+	C.JS_FreeValue(c.raw, argument)
+	C.JS_FreeValue(c.raw, value)
+	return retval
+}
+
+// Go error attached to error object by throw
+func (c *Context) goError(value C.JSValue) error {
+	property, ok := Value{c, value}.Object().ownDataProperty(c.errorAtom)
+	if !ok {
+		return nil
+	}
+	defer C.JS_FreeValue(c.raw, property)
+	if C.JS_IsObject(property) == 0 || C.JS_GetClassID(property) != c.runtime.goObject {
+		return nil
+	}
+	err, _ := getObjectData(property).value.(error)
+	return err
+}
+
+// Error object converted from err, to be thrown or to reject promise
+func (c *Context) errorValue(err error) C.JSValue {
+	c.throw(err)
+	return C.JS_GetException(c.raw)
+}
+
 func (c *Context) ThrowInternalError(format string, args ...any) C.JSValue {
+	return throwInternalError(c.raw, format, args...)
+}
+
+// Throw on raw context which may be the compiler context
+func throwInternalError(raw *C.JSContext, format string, args ...any) C.JSValue {
 	var buf strings.Builder
 	fmt.Fprintf(&buf, format, args...)
 	buf.WriteByte(0)
-	return C.ThrowInternalError(c.raw, strPtr(buf.String()))
+	return C.ThrowInternalError(raw, strPtr(buf.String()))
 }
@@ -0,0 +1,163 @@
+package quickjs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		options := EvalOptions{Filename: "tenant.js"}
+		_, err := context.EvalWithOptions(`
+			function validate() {
+				const cause = new TypeError("not a number", { cause: { code: 1 } })
+				throw Object.assign(new RangeError("out of range", { cause }), { status: 400 })
+			}
+			[1].forEach(validate)`, options)
+		var jsError *Error
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "RangeError", jsError.Name)
+		assert.Equal(t, "out of range", jsError.Message)
+		assert.Equal(t, "RangeError: out of range", err.Error())
+		assert.Equal(t, map[string]any{"status": 400}, jsError.Value)
+		assert.Equal(t, []StackFrame{
+			{Function: "validate", File: "tenant.js", Line: 4},
+			{Function: "forEach", Native: true},
+			{Function: "<eval>", File: "tenant.js", Line: 6},
+		}, jsError.Stack)
+		assert.Equal(t, "forEach (native)", jsError.Stack[1].String())
+		assert.Contains(t, jsError.RawStack, "    at validate (tenant.js:4)\n")
+		assert.Equal(t, "RangeError: out of range", jsError.Cause)
+
+		var cause *Error
+		assert.True(t, errors.As(errors.Unwrap(err), &cause))
+		assert.Equal(t, "TypeError", cause.Name)
+		assert.Equal(t, map[string]any{"code": 1}, cause.Unwrap().(*Error).Value)
+
+		_, err = context.Eval(`throw { code: 1 }`)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Empty(t, jsError.Name)
+		assert.Equal(t, map[string]any{"code": 1}, jsError.Value)
+
+		_, err = context.EvalWithOptions("1 +", options)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "SyntaxError", jsError.Name)
+		assert.Equal(t, []StackFrame{{File: "tenant.js", Line: 1}}, jsError.Stack)
+		assert.Nil(t, jsError.Value)
+
+		_, err = context.Eval(`const e = new Error("loop"); e.cause = e; throw e`)
+		assert.Error(t, err)
+
+		// Getters are not called while converting
+		_, err = context.Eval(`
+			var called = false
+			const getter = new Error("getter")
+			Object.defineProperty(getter, "code", { get() { called = true }, enumerable: true })
+			throw getter`)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Nil(t, jsError.Value)
+		called, _ := context.Eval(`called`)
+		assert.Equal(t, false, called.ToNative())
+
+		_, err = context.Eval(`
+			throw { get code() { called = true }, nested: { get code() { called = true } } }`)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, map[string]any{"nested": map[string]any{}}, jsError.Value)
+		_, err = context.Eval(`
+			const detail = new Error("detail")
+			detail.detail = { list: [{ get code() { called = true } }] }
+			throw detail`)
+		assert.ErrorAs(t, err, &jsError)
+		expected := map[string]any{"detail": map[string]any{"list": []any{map[string]any{}}}}
+		assert.Equal(t, expected, jsError.Value)
+		called, _ = context.Eval(`called`)
+		assert.Equal(t, false, called.ToNative())
+
+		// Cyclic values are truncated
+		_, err = context.Eval(`let o = { list: [] }; o.self = o; o.list.push(o); throw o`)
+		assert.ErrorAs(t, err, &jsError)
+		circular := NotNative{"[Circular]"}
+		expected = map[string]any{"self": circular, "list": []any{circular}}
+		assert.Equal(t, expected, jsError.Value)
+		_, err = context.Eval(`const self = new Error("self"); self.self = self; throw self`)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, map[string]any{"self": circular}, jsError.Value)
+		_, err = context.Eval(`let deep = {}; for (let i = 0; i < 1e5; i++) deep = { deep }; throw deep`)
+		assert.ErrorAs(t, err, &jsError)
+	})
+}
+
+var errNotFound = errors.New("not found")
+
+type keyError struct{ key string }
+
+func (e *keyError) Error() string { return "invalid key " + e.key }
+
+func TestGoError(t *testing.T) {
+	runtime := NewRuntime(Config{Debug: true, LeakHandler: FailOnLeak(t)})
+	runtime.NewContext().With(func(context *Context) {
+		context.GlobalObject().SetFunc("find", func(call Call) (Value, error) {
+			if call.Arg(0).Type() != TypeString {
+				return call.ToValue(Undefined), TypeError("expect string")
+			}
+			return call.ToValue(Undefined), fmt.Errorf("find %s: %w", call.Arg(0), errNotFound)
+		})
+		_, err := context.Eval(`function lookup(key) { return find(key) }; lookup("user")`)
+		assert.ErrorIs(t, err, errNotFound)
+		var jsError *Error
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "InternalError: find user: not found", err.Error())
+		assert.Equal(t, "lookup", jsError.Stack[0].Function)
+		assert.Nil(t, jsError.Value)
+
+		value, err := context.Eval(`
+			try { find("user") } catch (e) {
+				[e instanceof InternalError, e.message, Object.keys(e).length, JSON.stringify(e)]
+			}`)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{true, "find user: not found", 0, "{}"}, value.ToNative())
+
+		_, err = context.Eval(`
+			try { find("user") } catch (e) { throw new Error("wrapped", { cause: e }) }`)
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "wrapped", jsError.Message)
+
+		_, err = context.Eval(`find(1)`)
+		assert.Equal(t, "TypeError: expect string", err.Error())
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "TypeError", jsError.Name)
+		assert.Error(t, jsError.Err)
+
+		context.GlobalObject().SetFunc("check", func(call Call) (Value, error) {
+			return call.ToValue(Undefined), &keyError{call.Arg(0).String()}
+		})
+		_, err = context.Eval(`
+			try { check("user") } catch (e) { throw new Error("wrapped", { cause: e }) }`)
+		var keyErr *keyError
+		assert.ErrorAs(t, err, &keyErr)
+		assert.Equal(t, "user", keyErr.key)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Nil(t, jsError.Err)
+
+		// Only the hidden symbol holds Go error, other keys named alike are kept
+		_, err = context.Eval(`
+			throw Object.assign(new Error("keys"), { goError: 1, [Symbol("goError")]: 2 })`)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, map[string]any{"goError": 1}, jsError.Value)
+		assert.Nil(t, jsError.Err)
+	})
+}
+
+func TestParseStack(t *testing.T) {
+	frames := parseStack("    at f (a.js:1:2)\n    at <anonymous> (b.js)\n    at stripped\n")
+	assert.Equal(t, []StackFrame{
+		{Function: "f", File: "a.js", Line: 1, Column: 2},
+		{Function: "<anonymous>", File: "b.js"},
+		{Function: "stripped"},
+	}, frames)
+	assert.Equal(t, "f (a.js:1:2)", frames[0].String())
+}
@@ -0,0 +1,115 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+
+type EvalType uint8
+
+const (
+	EvalAuto   EvalType = iota // Evaluate as module only if code contains import or export
+	EvalGlobal                 // Evaluate as global script
+	EvalModule                 // Evaluate as ES module
+)
+
+type EvalOptions struct {
+	Filename    string // Shown in stack trace and used as module name, "<input>" if empty
+	Line        int    // Line number of first line of code, 1 if zero
+	Type        EvalType
+	Strict      bool // Force strict mode, modules are always strict
+	CompileOnly bool // Check syntax only and return undefined
+}
+
+func (o EvalOptions) filename() string {
+	if o.Filename == "" {
+		return "<input>"
+	}
+	return o.Filename
+}
+
+func (o EvalOptions) line() int {
+	return max(o.Line, 1)
+}
+
+func (o EvalOptions) flags(code string) C.int {
+	flags := C.int(C.JS_EVAL_TYPE_GLOBAL)
+	switch o.Type {
+	case EvalModule:
+		flags = C.JS_EVAL_TYPE_MODULE
+	case EvalAuto:
+		if C.JS_DetectModule(strPtr(code+"\x00"), strlen(code)) != 0 {
+			flags = C.JS_EVAL_TYPE_MODULE
+		}
+	}
+	if o.Strict {
+		flags |= C.JS_EVAL_FLAG_STRICT
+	}
+	if o.CompileOnly {
+		flags |= C.JS_EVAL_FLAG_COMPILE_ONLY
+	}
+	return flags
+}
+
+func (c *Context) evalWithOptions(code string, options EvalOptions) (C.JSValue, error) {
+	flags := options.flags(code)
+	jsValue := c.jsEval(code, options.filename(), options.line(), flags)
+	if err := c.checkException(jsValue); err != nil {
+		return null, err
+	}
+	if options.CompileOnly {
+		C.JS_FreeValue(c.raw, jsValue)
+		return C.JS_Undefined(), nil
+	}
+	if flags&C.JS_EVAL_TYPE_MODULE != 0 {
+		if err := c.checkPromise(jsValue); err != nil {
+			C.JS_FreeValue(c.raw, jsValue)
+			return null, err
+		}
+	}
+	return jsValue, nil
+}
+
+// Return value must be consumed before next Eval or EvalBinary, or retained by Dup
+func (c *Context) EvalWithOptions(code string, options EvalOptions) (Value, error) {
+	C.JS_FreeValue(c.raw, c.evalRet)
+	c.evalRet = null // Evaluation may panic with Config.Repanic
+	value, err := c.evalWithOptions(code, options)
+	c.evalRet = value
+	return Value{c, value}, err
+}
+
+// Debug information removed from ByteCode by Compile
+type StripLevel uint8
+
+const (
+	StripNone StripLevel = iota
+	// Strip filename and line numbers, so stack traces lack locations
+	StripDebug
+	// Strip variable names as well, only function names are kept
+	StripAll
+)
+
+// Source text is never serialized into ByteCode, so Function.prototype.toString
+// of loaded functions doesn't return source regardless of options
+type CompileOptions struct {
+	EvalOptions // CompileOnly is ignored
+	Strip       StripLevel
+}
+
+func (c *Context) CompileWithOptions(code string, options CompileOptions) (ByteCode, error) {
+	options.CompileOnly = true
+	flags := options.flags(code)
+	if options.Strip == StripAll {
+		flags |= C.JS_EVAL_FLAG_STRIP
+	}
+	jsValue := c.jsEval(code, options.filename(), options.line(), flags)
+	if err := c.checkException(jsValue); err != nil {
+		return nil, err
+	}
+	byteCode, err := c.writeByteCode(jsValue)
+	if err != nil || options.Strip != StripDebug {
+		return byteCode, err
+	}
+	// quickjs strips variable names together with debug information,
+	// so debug information alone is removed from serialized functions
+	return byteCode.stripDebug()
+}
@@ -0,0 +1,60 @@
+package quickjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalWithOptions(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		options := EvalOptions{Filename: "tenant.js", Line: 10}
+		_, err := context.EvalWithOptions("let a = 1\nthrow new Error('oops')", options)
+		assert.Error(t, err)
+		frame := StackFrame{Function: "<eval>", File: "tenant.js", Line: 11}
+		assert.Equal(t, []StackFrame{frame}, err.(*Error).Stack)
+
+		// Line is passed to the engine, so source text is kept as is
+		options = EvalOptions{Filename: "script.js", Line: 5}
+		code := "#!/usr/bin/env qjs\nthrow new Error('oops')"
+		_, err = context.EvalWithOptions(code, options)
+		frame = StackFrame{Function: "<eval>", File: "script.js", Line: 6}
+		assert.Equal(t, []StackFrame{frame}, err.(*Error).Stack)
+
+		_, err = context.EvalWithOptions("undeclared = 1", EvalOptions{Strict: true})
+		assert.Error(t, err)
+
+		options = EvalOptions{Type: EvalModule}
+		_, err = context.EvalWithOptions("globalThis.isModule = this === undefined", options)
+		assert.NoError(t, err)
+		value, _ := context.Eval("isModule")
+		assert.Equal(t, true, value.ToNative())
+
+		options = EvalOptions{Type: EvalGlobal}
+		_, err = context.EvalWithOptions("import('whatever').catch(() => {}); var g = 1", options)
+		assert.NoError(t, err)
+		value, _ = context.Eval("g")
+		assert.Equal(t, 1, value.ToNative())
+
+		value, err = context.EvalWithOptions("globalThis.h = 1", EvalOptions{CompileOnly: true})
+		assert.NoError(t, err)
+		assert.Equal(t, Undefined, value.ToNative())
+		value, _ = context.Eval("typeof h")
+		assert.Equal(t, "undefined", value.ToNative())
+
+		_, err = context.EvalWithOptions("1 +", EvalOptions{CompileOnly: true})
+		assert.Error(t, err)
+	})
+}
+
+func TestCompileWithOptions(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		options := CompileOptions{EvalOptions: EvalOptions{Filename: "compiled.js", Line: 3}}
+		byteCode, err := context.CompileWithOptions("throw new Error('oops')", options)
+		assert.NoError(t, err)
+		_, err = context.EvalBinary(byteCode)
+		assert.Error(t, err)
+		assert.Equal(t, "compiled.js", err.(*Error).Stack[0].File)
+		assert.Equal(t, 3, err.(*Error).Stack[0].Line)
+	})
+}
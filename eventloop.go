@@ -0,0 +1,314 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Time source of EventLoop timers
+type Clock interface {
+	Now() time.Time
+	// Channel delivering once duration elapsed
+	After(time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Clock which only moves by Advance, event loop waiting for next timer
+// is woken once the clock is advanced to the timer by another goroutine
+type VirtualClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+}
+
+// Channel returned by VirtualClock.After and its due time
+type clockWaiter struct {
+	due    time.Time
+	notify chan time.Time
+}
+
+func NewVirtualClock(now time.Time) *VirtualClock { return &VirtualClock{now: now} }
+
+func (v *VirtualClock) Now() time.Time {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.now
+}
+
+// Move the clock forward, delivering to channels returned by After once due
+func (v *VirtualClock) Advance(d time.Duration) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.now = v.now.Add(d)
+	waiters := v.waiters[:0]
+	for _, waiter := range v.waiters {
+		if waiter.due.After(v.now) {
+			waiters = append(waiters, waiter)
+		} else {
+			waiter.notify <- v.now
+		}
+	}
+	v.waiters = waiters
+}
+
+func (v *VirtualClock) After(d time.Duration) <-chan time.Time {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	retval := make(chan time.Time, 1)
+	if d <= 0 {
+		retval <- v.now
+	} else {
+		v.waiters = append(v.waiters, clockWaiter{v.now.Add(d), retval})
+	}
+	return retval
+}
+
+type timer struct {
+	id       int
+	due      time.Time
+	interval time.Duration // Zero for setTimeout
+	seq      uint64        // Timers of same due time fire in order of scheduling
+	index    int
+	fn       C.JSValue
+	args     []C.JSValue
+}
+
+type timerHeap []*timer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].due.Before(h[j].due)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *timerHeap) Push(x any) {
+	timer := x.(*timer)
+	timer.index = len(*h)
+	*h = append(*h, timer)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	timer := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return timer
+}
+
+// EventLoop implements setTimeout, setInterval, clearTimeout, clearInterval
+// and queueMicrotask globals of a context, timers are fired by Run or
+// RunUntilIdle, as well as by Value.Await while waiting for promise
+type EventLoop struct {
+	context *Context
+	clock   Clock
+	timers  timerHeap
+	byID    map[int]*timer
+	lastID  int
+	seq     uint64
+
+	unhandled []error // Rejections reported during current run
+}
+
+// Install timer globals to context, timers are driven by system clock
+// if clock is not specified. Calling again returns the same event loop
+func (c *Context) NewEventLoop(clock ...Clock) *EventLoop {
+	if c.eventLoop != nil {
+		return c.eventLoop
+	}
+	loop := &EventLoop{context: c, clock: systemClock{}, byID: make(map[int]*timer)}
+	if len(clock) > 0 {
+		loop.clock = clock[0]
+	}
+	global := c.GlobalObject()
+	global.SetFunc("setTimeout", func(call Call) (Value, error) { return loop.setTimer(call, false) })
+	global.SetFunc("setInterval", func(call Call) (Value, error) { return loop.setTimer(call, true) })
+	global.SetFunc("clearTimeout", loop.clearTimer)
+	global.SetFunc("clearInterval", loop.clearTimer)
+	global.SetFunc("queueMicrotask", loop.queueMicrotask)
+	c.eventLoop = loop
+	return loop
+}
+
+func (l *EventLoop) callback(call Call) (C.JSValue, error) {
+	if call.NumArgs() == 0 || !call.Arg(0).Object().IsFunction() {
+		return null, TypeError("callback is not a function")
+	}
+	return call.args[0], nil
+}
+
+func (l *EventLoop) setTimer(call Call, repeat bool) (Value, error) {
+	fn, err := l.callback(call)
+	if err != nil {
+		return l.context.ToValue(Undefined), err
+	}
+	var delay C.double
+	if call.NumArgs() > 1 && C.JS_ToFloat64(l.context.raw, &delay, call.args[1]) < 0 {
+		return l.context.ToValue(Undefined), l.context.getException()
+	}
+	if math.IsNaN(float64(delay)) || delay < 0 {
+		delay = 0
+	}
+	duration := time.Duration(float64(delay) * float64(time.Millisecond))
+	l.lastID++
+	l.seq++
+	timer := &timer{id: l.lastID, due: l.clock.Now().Add(duration), seq: l.seq}
+	if repeat {
+		timer.interval = max(duration, time.Millisecond)
+	}
+	timer.fn = C.JS_DupValue(l.context.raw, fn)
+	for i := 2; i < call.NumArgs(); i++ {
+		timer.args = append(timer.args, C.JS_DupValue(l.context.raw, call.args[i]))
+	}
+	heap.Push(&l.timers, timer)
+	l.byID[timer.id] = timer
+	return l.context.ToValue(timer.id), nil
+}
+
+func (l *EventLoop) free(timer *timer) {
+	delete(l.byID, timer.id)
+	C.JS_FreeValue(l.context.raw, timer.fn)
+	for _, arg := range timer.args {
+		C.JS_FreeValue(l.context.raw, arg)
+	}
+}
+
+func (l *EventLoop) clearTimer(call Call) (Value, error) {
+	if call.NumArgs() > 0 {
+		var id C.int32_t
+		if C.JS_ToInt32(l.context.raw, &id, call.args[0]) < 0 {
+			return l.context.ToValue(Undefined), l.context.getException()
+		}
+		if timer, ok := l.byID[int(id)]; ok {
+			heap.Remove(&l.timers, timer.index)
+			l.free(timer)
+		}
+	}
+	return l.context.ToValue(Undefined), nil
+}
+
+func (l *EventLoop) queueMicrotask(call Call) (Value, error) {
+	fn, err := l.callback(call)
+	if err != nil {
+		return l.context.ToValue(Undefined), err
+	}
+	if C.EnqueueCall(l.context.raw, fn) < 0 {
+		return l.context.ToValue(Undefined), l.context.getException()
+	}
+	return l.context.ToValue(Undefined), nil
+}
+
+// Fire earliest timer if due, return false if there is none
+func (l *EventLoop) runTimer() (bool, error) {
+	if len(l.timers) == 0 || l.timers[0].due.After(l.clock.Now()) {
+		return false, nil
+	}
+	c, timer := l.context, l.timers[0]
+	// Callback may clear the timer, so references are held during call
+	args := make([]C.JSValue, len(timer.args)+1) // Never empty
+	for i, arg := range timer.args {
+		args[i] = C.JS_DupValue(c.raw, arg)
+	}
+	fn := C.JS_DupValue(c.raw, timer.fn)
+	if timer.interval > 0 {
+		timer.due = l.clock.Now().Add(timer.interval)
+		l.seq++
+		timer.seq = l.seq
+		heap.Fix(&l.timers, 0)
+	} else {
+		heap.Pop(&l.timers)
+		l.free(timer)
+	}
+	retval := C.JS_Call(c.raw, fn, null, C.int(len(timer.args)), &args[0])
+	C.JS_FreeValue(c.raw, fn)
+	for _, arg := range args[:len(timer.args)] {
+		C.JS_FreeValue(c.raw, arg)
+	}
+	if err := c.checkException(retval); err != nil {
+		return true, err
+	}
+	C.JS_FreeValue(c.raw, retval)
+	return true, nil
+}
+
+// Channel delivering when next timer is due, nil if there is no timer
+func (l *EventLoop) nextTimer() <-chan time.Time {
+	if len(l.timers) == 0 {
+		return nil
+	}
+	return l.clock.After(l.timers[0].due.Sub(l.clock.Now()))
+}
+
+// Release pending timers on Context.Free
+func (l *EventLoop) release() {
+	for _, timer := range l.timers {
+		l.free(timer)
+	}
+	l.timers = nil
+}
+
+// Number of pending timers
+func (l *EventLoop) Len() int { return len(l.timers) }
+
+// Error of the run joined with UnhandledRejectionError reported during the run
+func (l *EventLoop) result(err error) error {
+	unhandled := l.unhandled
+	l.unhandled = nil
+	if len(unhandled) == 0 {
+		return err
+	}
+	return errors.Join(append(unhandled, err)...)
+}
+
+// Execute pending jobs and due timers without waiting,
+// return when nothing is ready or a job or timer callback throws
+func (l *EventLoop) RunUntilIdle() error {
+	l.unhandled = nil
+	for {
+		executed, err := l.context.runJob()
+		if !executed || err != nil {
+			return l.result(err)
+		}
+	}
+}
+
+// Execute pending jobs and timers, waiting for timers and AsyncFunc results,
+// until none remains or ctx is done. Returns error thrown by a job or timer
+// callback, or error wrapping both ErrInterrupted and ctx.Err(), joined with
+// UnhandledRejectionError of promises rejected without handler during the run
+func (l *EventLoop) Run(ctx context.Context) error {
+	c := l.context
+	l.unhandled = nil
+	_, err := c.withContext(ctx, func() (Value, error) {
+		for {
+			if err := (cancellation{ctx}).interrupt(); err != nil {
+				return c.ToValue(Undefined), err
+			}
+			executed, err := c.runJob()
+			if err != nil {
+				return c.ToValue(Undefined), err
+			}
+			if !executed && !c.wait(ctx) {
+				return c.ToValue(Undefined), nil
+			}
+		}
+	})
+	return l.result(err)
+}
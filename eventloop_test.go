@@ -0,0 +1,99 @@
+package quickjs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventLoop(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	clock := NewVirtualClock(time.Unix(0, 0))
+	runtime := NewRuntime(Config{ManualFree: true, Debug: true, LeakHandler: FailOnLeak(t)})
+	guard := runtime.NewContext()
+	guard.With(func(context *Context) {
+		loop := context.NewEventLoop(clock)
+		_, err := context.Eval(`
+			var log = []
+			setTimeout((a, b) => log.push("timeout " + (a + b)), 100, 1, 2)
+			setTimeout(() => log.push("cleared"), 50)
+			clearTimeout(2)
+			var count = 0
+			var interval = setInterval(() => { if (++count == 3) clearInterval(interval) }, 10)
+			queueMicrotask(() => log.push("microtask"))
+			Promise.resolve().then(() => log.push("promise"))`)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, loop.Len())
+		assert.NoError(t, loop.RunUntilIdle())
+		log, _ := context.GlobalObject().GetProperty("log")
+		assert.Equal(t, []any{"microtask", "promise"}, log.ToNative())
+
+		clock.Advance(10 * time.Millisecond)
+		assert.NoError(t, loop.RunUntilIdle())
+		count, _ := context.GlobalObject().GetProperty("count")
+		assert.Equal(t, 1, count.ToNative())
+
+		clock.Advance(90 * time.Millisecond) // Interval is rescheduled from now
+		assert.NoError(t, loop.RunUntilIdle())
+		log, _ = context.GlobalObject().GetProperty("log")
+		assert.Equal(t, []any{"microtask", "promise", "timeout 3"}, log.ToNative())
+		count, _ = context.GlobalObject().GetProperty("count")
+		assert.Equal(t, 2, count.ToNative())
+		clock.Advance(10 * time.Millisecond)
+		assert.NoError(t, loop.RunUntilIdle())
+		count, _ = context.GlobalObject().GetProperty("count")
+		assert.Equal(t, 3, count.ToNative())
+		assert.Equal(t, 0, loop.Len())
+
+		// Waiting for timer doesn't move the clock
+		start := clock.Now()
+		value, _ := context.Eval(`new Promise(resolve => setTimeout(() => resolve("done"), 1000))`)
+		go clock.Advance(time.Second)
+		result, err := value.Await(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "done", result.ToNative())
+		assert.Equal(t, time.Second, clock.Now().Sub(start))
+
+		_, err = context.Eval(`setTimeout(() => { throw new Error("boom") })`)
+		assert.NoError(t, err)
+		assert.ErrorContains(t, loop.Run(ctx), "boom")
+
+		_, err = context.Eval(`setTimeout("code")`)
+		assert.Error(t, err)
+		_, err = context.Eval(`setTimeout(() => {}, { valueOf() { throw new Error("delay") } })`)
+		assert.ErrorContains(t, err, "delay")
+		_, err = context.Eval(`clearTimeout({ valueOf() { throw new Error("id") } })`)
+		assert.ErrorContains(t, err, "id")
+		_, err = context.Eval(`setInterval(() => {}, 1000)`)
+		assert.NoError(t, err)
+	})
+	guard.Free() // Pending interval is released
+	runtime.Free()
+}
+
+func TestVirtualClock(t *testing.T) {
+	clock := NewVirtualClock(time.Unix(0, 0))
+	after := clock.After(10 * time.Millisecond)
+	assert.Equal(t, time.Unix(0, 0), clock.Now())
+	clock.Advance(5 * time.Millisecond)
+	assert.Len(t, after, 0)
+	clock.Advance(5 * time.Millisecond)
+	assert.Equal(t, time.Unix(0, 0).Add(10*time.Millisecond), <-after)
+	assert.Len(t, clock.After(0), 1)
+}
+
+func TestEventLoopContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	NewRuntime().NewContext().With(func(context *Context) {
+		loop := context.NewEventLoop()
+		_, err := context.Eval(`setInterval(() => {}, 1)`)
+		assert.NoError(t, err)
+		err = loop.Run(ctx)
+		assert.ErrorIs(t, err, ErrInterrupted)
+		assert.ErrorIs(t, err, ctx.Err())
+	})
+}
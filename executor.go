@@ -0,0 +1,119 @@
+package quickjs
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Executor owns a Runtime and its Context on a dedicated os thread,
+// any goroutine may schedule work to it with Submit or Do.
+//
+// An Executor owns exactly one Context, every job receives the same one, so
+// globals defined by a job are visible to later jobs. Use one Executor per
+// Context when isolation is required.
+//
+// Scheduling work from inside a job of the same Executor will deadlock.
+type Executor struct {
+	jobs    chan func()
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+	context *Context
+}
+
+// Runtime is always manually freed regardless of Config.ManualFree
+func NewExecutor(config ...Config) *Executor {
+	executor := &Executor{jobs: make(chan func(), 64), done: make(chan struct{})}
+	ready := make(chan struct{})
+	go executor.run(config, ready)
+	<-ready
+	return executor
+}
+
+func (e *Executor) run(configs []Config, ready chan<- struct{}) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	config := DefaultConfig()
+	if len(configs) > 0 {
+		config = configs[0]
+	}
+	config.ManualFree = true
+	jsRuntime := NewRuntime(config)
+	e.context = jsRuntime.NewContext().Unwrap()
+	close(ready)
+	for job := range e.jobs {
+		if err := protect(job); err != nil {
+			log.Printf("quickjs: executor job %v\n%s", err, err.Stack)
+		}
+	}
+	e.context.Free()
+	jsRuntime.Free()
+	close(e.done)
+}
+
+// Recover panic of job so that executor thread survives
+func protect(job func()) (err *PanicError) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+	job()
+	return nil
+}
+
+func (e *Executor) schedule(ctx context.Context, job func()) error {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.closed {
+		return ErrExecutorClosed
+	}
+	select {
+	case e.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Schedule fn to executor thread without waiting for it,
+// panic of fn is recovered and logged
+func (e *Executor) Submit(fn func(*Context)) error {
+	return e.schedule(context.Background(), func() { fn(e.context) })
+}
+
+// Schedule fn to executor thread and wait for its result,
+// javascript evaluated by fn is interrupted once ctx is done.
+// Panic of fn is recovered and returned as *PanicError
+func (e *Executor) Do(ctx context.Context, fn func(*Context) error) error {
+	result := make(chan error, 1)
+	job := func() {
+		var err error
+		if panicked := protect(func() {
+			_, err = e.context.withContext(ctx, func() (Value, error) {
+				return Value{}, fn(e.context)
+			})
+		}); panicked != nil {
+			err = panicked
+		}
+		result <- err
+	}
+	if err := e.schedule(ctx, job); err != nil {
+		return err
+	}
+	return <-result
+}
+
+// Wait for scheduled jobs to finish then free context and runtime
+func (e *Executor) Close() {
+	e.mutex.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.jobs)
+	}
+	e.mutex.Unlock()
+	<-e.done
+}
@@ -0,0 +1,90 @@
+package quickjs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutor(t *testing.T) {
+	executor := NewExecutor()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result any
+			err := executor.Do(context.Background(), func(context *Context) error {
+				value, err := context.Eval(`globalThis.counter = (globalThis.counter || 0) + 1`)
+				result = value.ToNative()
+				return err
+			})
+			assert.NoError(t, err)
+			assert.IsType(t, 0, result)
+		}()
+	}
+	wg.Wait()
+
+	done := make(chan any, 1)
+	assert.NoError(t, executor.Submit(func(context *Context) {
+		value, _ := context.Eval("counter")
+		done <- value.ToNative()
+	}))
+	assert.Equal(t, 8, <-done)
+
+	executor.Close()
+	assert.ErrorIs(t, executor.Submit(func(*Context) {}), ErrExecutorClosed)
+	err := executor.Do(context.Background(), func(*Context) error { return nil })
+	assert.ErrorIs(t, err, ErrExecutorClosed)
+}
+
+func TestExecutorInterrupt(t *testing.T) {
+	executor := NewExecutor()
+	defer executor.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := executor.Do(ctx, func(context *Context) error {
+		_, err := context.Eval("while (true) {}")
+		return err
+	})
+	assert.ErrorIs(t, err, ErrInterrupted)
+}
+
+func TestExecutorPanic(t *testing.T) {
+	executor := NewExecutor()
+	defer executor.Close()
+	err := executor.Do(context.Background(), func(*Context) error { panic("oops") })
+	var panicked *PanicError
+	assert.ErrorAs(t, err, &panicked)
+	assert.Equal(t, "oops", panicked.Value)
+
+	assert.NoError(t, executor.Submit(func(*Context) { panic("oops") }))
+	err = executor.Do(context.Background(), func(context *Context) error {
+		_, err := context.Eval("1")
+		return err
+	})
+	assert.NoError(t, err)
+}
+
+func TestExecutorSingleContext(t *testing.T) {
+	executor := NewExecutor()
+	defer executor.Close()
+	contexts := make(chan *Context, 2)
+	assert.NoError(t, executor.Submit(func(context *Context) { contexts <- context }))
+	err := executor.Do(context.Background(), func(context *Context) error {
+		contexts <- context
+		_, err := context.Eval("globalThis.shared = 1")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Same(t, <-contexts, <-contexts)
+	err = executor.Do(context.Background(), func(context *Context) error {
+		value, err := context.Eval("shared")
+		assert.Equal(t, 1, value.ToNative())
+		return err
+	})
+	assert.NoError(t, err)
+}
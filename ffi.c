@@ -16,3 +16,59 @@ JSClassDef go_classes[3] = {{
 JSValue ThrowInternalError(JSContext *ctx, const char *fmt) {
     return JS_ThrowInternalError(ctx, "%s", fmt);
 }
+
+JSValue ThrowError(JSContext *ctx, ErrorClass class, const char *message) {
+    switch (class) {
+    case SYNTAX_ERROR:
+        return JS_ThrowSyntaxError(ctx, "%s", message);
+    case TYPE_ERROR:
+        return JS_ThrowTypeError(ctx, "%s", message);
+    case REFERENCE_ERROR:
+        return JS_ThrowReferenceError(ctx, "%s", message);
+    case RANGE_ERROR:
+        return JS_ThrowRangeError(ctx, "%s", message);
+    default:
+        return JS_ThrowInternalError(ctx, "%s", message);
+    }
+}
+
+void SetInterruptHandler(JSRuntime *rt, void *opaque) {
+    JS_SetInterruptHandler(rt, interruptHandler, opaque);
+}
+
+static char *normalizeModule(JSContext *ctx, const char *base, const char *name, void *opaque) {
+    return goNormalizeModule(ctx, (char *)base, (char *)name, opaque);
+}
+
+static JSModuleDef *loadModule(JSContext *ctx, const char *name, void *opaque) {
+    return goLoadModule(ctx, (char *)name, opaque);
+}
+
+void SetModuleLoader(JSRuntime *rt, void *opaque) {
+    if (opaque == NULL) {
+        JS_SetModuleLoaderFunc(rt, NULL, NULL, NULL);
+        return;
+    }
+    JS_SetModuleLoaderFunc(rt, normalizeModule, loadModule, opaque);
+}
+
+static void trackRejection(JSContext *ctx, JSValueConst promise, JSValueConst reason,
+                           JS_BOOL is_handled, void *opaque) {
+    goTrackRejection(ctx, promise, reason, is_handled, opaque);
+}
+
+void SetPromiseRejectionTracker(JSRuntime *rt, void *opaque) {
+    JS_SetHostPromiseRejectionTracker(rt, trackRejection, opaque);
+}
+
+JSModuleDef *NewModule(JSContext *ctx, const char *name) {
+    return JS_NewCModule(ctx, name, goInitModule);
+}
+
+static JSValue callJob(JSContext *ctx, int argc, JSValueConst *argv) {
+    return JS_Call(ctx, argv[0], JS_UNDEFINED, 0, NULL);
+}
+
+int EnqueueCall(JSContext *ctx, JSValueConst fn) {
+    return JS_EnqueueJob(ctx, callJob, 1, &fn);
+}
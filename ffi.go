@@ -1,6 +1,9 @@
 package quickjs
 
-//#include "ffi.h"
+/*
+#cgo CFLAGS: -DCONFIG_BIGNUM
+#include "ffi.h"
+*/
 import "C"
 import (
 	"unsafe"
@@ -14,6 +17,7 @@ type goObjectData struct {
 
 //export goObjectFinalizer
 func goObjectFinalizer(_ *C.JSRuntime, value C.JSValue) {
+	defer recoverLoggedPanic("finalizer")
 	dataPtr := C.JS_GetOpaque(value, C.JS_GetClassID(value))
 	data := (*goObjectData)(dataPtr)
 	delete(data.context.goValues, (uintptr)(C.JS_ValuePtr(value)))
@@ -30,25 +34,121 @@ func getObjectData(value C.JSValueConst) *goObjectData {
 }
 
 //export proxyCall
-func proxyCall(_ *jsCtx, fn, this jsValCst, argc C.int, argv *jsValCst, flags C.int) jsVal {
+func proxyCall(ctx *jsCtx, fn, this jsValCst, argc C.int, argv *jsValCst, flags C.int) (ret jsVal) {
+	defer getContext(ctx).recoverPanic(&ret)
 	args := unsafe.Slice(argv, argc)
 	data := getObjectData(fn)
 	retval, err := data.value.(Func)(Call{data.context, fn, this, args, flags})
 	if err != nil {
-		return data.context.ThrowInternalError("%s", err)
+		return data.context.throw(err)
 	}
-	return retval.raw
+	return data.context.consume(retval)
 }
 
 //export indexCall
-func indexCall(_ *jsCtx, fn, this jsValCst, argc C.int, argv *jsValCst, flags C.int) jsVal {
+func indexCall(ctx *jsCtx, fn, this jsValCst, argc C.int, argv *jsValCst, flags C.int) (ret jsVal) {
+	defer getContext(ctx).recoverPanic(&ret)
 	args := unsafe.Slice(argv, argc)
 	data := getObjectData(this)
 	index := int(uintptr(C.JS_GetOpaque(fn, C.JS_GetClassID(fn))))
 	call := Call{data.context, fn, this, args, flags}
 	retval, err := data.value.(IndexCallable).IndexCall(index, call)
 	if err != nil {
-		return data.context.ThrowInternalError("%s", err)
+		return data.context.throw(err)
 	}
-	return retval.raw
+	return data.context.consume(retval)
+}
+
+//export interruptHandler
+func interruptHandler(_ *C.JSRuntime, opaque unsafe.Pointer) C.int {
+	runtime := (*runtimeData)(opaque).runtime
+	for _, interrupter := range runtime.interrupters {
+		if err := interrupter.interrupt(); err != nil && runtime.interrupted == nil {
+			runtime.interrupted = err
+		}
+	}
+	if runtime.interrupted != nil {
+		return 1
+	}
+	return 0
+}
+
+//export goNormalizeModule
+func goNormalizeModule(ctx *jsCtx, base, name *C.char, opaque unsafe.Pointer) (ret *C.char) {
+	defer recoverModulePanic(ctx, &ret, nil)
+	context, specifier := getContext(ctx), [2]string{C.GoString(base), C.GoString(name)}
+	resolved, ok := context.resolved[specifier]
+	loader := (*runtimeData)(opaque).runtime.loader
+	if !ok && loader == nil { // Evaluating bundle without module loader
+		resolved = specifier[1]
+	} else if !ok {
+		var err error
+		if resolved, err = loader.Resolve(specifier[0], specifier[1]); err != nil {
+			throwInternalError(ctx, "%s", err)
+			return nil
+		}
+		if context.bundle != nil {
+			context.bundle.resolved = append(context.bundle.resolved, specifier[:]...)
+			context.bundle.resolved = append(context.bundle.resolved, resolved)
+		}
+	}
+	return C.js_strdup(ctx, strPtr(resolved+"\x00"))
+}
+
+//export goTrackRejection
+func goTrackRejection(ctx *jsCtx, promise, reason jsValCst, handled C.int, opaque unsafe.Pointer) {
+	defer recoverLoggedPanic("rejection tracker")
+	runtime := (*runtimeData)(opaque).runtime
+	if handled == 0 {
+		context := getContext(ctx)
+		promise, reason = C.JS_DupValue(ctx, promise), C.JS_DupValue(ctx, reason)
+		runtime.rejections = append(runtime.rejections, rejection{context, promise, reason})
+		return
+	}
+	for i, rejection := range runtime.rejections {
+		if rejection.promise == promise {
+			rejection.free()
+			runtime.rejections = append(runtime.rejections[:i], runtime.rejections[i+1:]...)
+			break
+		}
+	}
+}
+
+//export goLoadModule
+func goLoadModule(ctx *jsCtx, name *C.char, opaque unsafe.Pointer) (ret *C.JSModuleDef) {
+	defer recoverModulePanic(ctx, &ret, nil)
+	context, moduleName := getContext(ctx), C.GoString(name)
+	loader := (*runtimeData)(opaque).runtime.loader
+	if loader == nil {
+		throwInternalError(ctx, "could not load module '%s'", moduleName)
+		return nil
+	}
+	code, err := loader.Load(moduleName)
+	if err != nil {
+		throwInternalError(ctx, "%s", err)
+		return nil
+	}
+	module := context.compileModule(ctx, moduleName, code)
+	if module != nil && context.bundle != nil {
+		context.bundle.modules = append(context.bundle.modules, module)
+	}
+	return module
+}
+
+//export goInitModule
+func goInitModule(ctx *jsCtx, module *C.JSModuleDef) (ret C.int) {
+	defer recoverModulePanic(ctx, &ret, -1)
+	context := getContext(ctx)
+	if ctx == context.compiler { // Never evaluated
+		return 0
+	}
+	exports := context.modules[module]
+	delete(context.modules, module)
+	for name, value := range exports {
+		jsValue := context.toExport(value)
+		if C.JS_SetModuleExport(ctx, module, strPtr(name+"\x00"), jsValue) < 0 {
+			return -1
+		}
+	}
+	return 0
 }
@@ -4,13 +4,67 @@
 #include "libquickjs/quickjs-libc.h"
 
 static inline JS_BOOL JS_IsInt(JSValueConst val) { return JS_VALUE_GET_TAG(val) == JS_TAG_INT; }
+static inline JS_BOOL JS_IsModule(JSValueConst val) { return JS_VALUE_GET_TAG(val) == JS_TAG_MODULE; }
 
 static inline JSValue JS_Null() { return JS_NULL; }
 static inline JSValue JS_Undefined() { return JS_UNDEFINED; }
+static inline JSValue JS_Exception() { return JS_EXCEPTION; }
+static inline JSValue JS_ModuleValue(JSModuleDef *m) { return JS_MKPTR(JS_TAG_MODULE, m); }
 
 static inline void* JS_ValuePtr(JSValueConst val) { return JS_VALUE_GET_PTR(val); }
 static inline int JS_ValueTag(JSValueConst val) { return JS_VALUE_GET_TAG(val); }
+static inline void JS_SetOpaqueInt(JSValue obj, intptr_t val) { JS_SetOpaque(obj, (void *)val); }
+
+// First class IDs private to libquickjs/quickjs.c, which are fixed
+enum { JS_CLASS_OBJECT = 1, JS_CLASS_ARRAY, JS_CLASS_ERROR };
+
+// Mirror of serialization constants private to libquickjs/quickjs.c,
+// checked against the engine by bytecode_test.go
+#define BC_VERSION 0x43
+#define JS_TYPED_ARRAY_COUNT 11 // Uint8ClampedArray to Float64Array
+
+typedef enum {
+    BC_TAG_NULL = 1,
+    BC_TAG_UNDEFINED,
+    BC_TAG_BOOL_FALSE,
+    BC_TAG_BOOL_TRUE,
+    BC_TAG_INT32,
+    BC_TAG_FLOAT64,
+    BC_TAG_STRING,
+    BC_TAG_OBJECT,
+    BC_TAG_ARRAY,
+    BC_TAG_BIG_INT,
+    BC_TAG_TEMPLATE_OBJECT,
+    BC_TAG_FUNCTION_BYTECODE,
+    BC_TAG_MODULE,
+    BC_TAG_TYPED_ARRAY,
+    BC_TAG_ARRAY_BUFFER,
+    BC_TAG_SHARED_ARRAY_BUFFER,
+    BC_TAG_DATE,
+    BC_TAG_OBJECT_VALUE,
+    BC_TAG_OBJECT_REFERENCE,
+    BC_TAG_BIG_FLOAT,
+    BC_TAG_BIG_DECIMAL,
+} BCTagEnum;
+
+// Builtin atoms, which requires CONFIG_BIGNUM same as libquickjs
+enum {
+    __JS_ATOM_NULL = JS_ATOM_NULL,
+#define DEF(name, str) JS_ATOM_ ## name,
+#include "libquickjs/quickjs-atom.h"
+#undef DEF
+    JS_ATOM_END,
+};
+
+typedef enum { INTERNAL_ERROR, SYNTAX_ERROR, TYPE_ERROR, REFERENCE_ERROR, RANGE_ERROR } ErrorClass;
 
 extern JSValue ThrowInternalError(JSContext *ctx, const char *fmt);
+extern JSValue ThrowError(JSContext *ctx, ErrorClass class, const char *message);
+
+extern void SetInterruptHandler(JSRuntime *rt, void *opaque);
+extern void SetModuleLoader(JSRuntime *rt, void *opaque);
+extern void SetPromiseRejectionTracker(JSRuntime *rt, void *opaque);
+extern JSModuleDef *NewModule(JSContext *ctx, const char *name);
+extern int EnqueueCall(JSContext *ctx, JSValueConst fn);
 
 extern JSClassDef go_classes[3];
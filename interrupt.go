@@ -0,0 +1,54 @@
+package quickjs
+
+import (
+	"context"
+	"fmt"
+)
+
+type interrupter interface {
+	// Non-nil error aborts running javascript
+	interrupt() error
+}
+
+type cancellation struct{ context.Context }
+
+func (c cancellation) interrupt() error {
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInterrupted, err)
+	}
+	return nil
+}
+
+func (c *Context) interruptible(interrupter interrupter, fn func() (Value, error)) (Value, error) {
+	runtime := c.runtime
+	runtime.interrupters = append(runtime.interrupters, interrupter)
+	defer func() { runtime.interrupters = runtime.interrupters[:len(runtime.interrupters)-1] }()
+	return fn()
+}
+
+func (c *Context) withContext(ctx context.Context, fn func() (Value, error)) (Value, error) {
+	if err := (cancellation{ctx}).interrupt(); err != nil {
+		return c.ToValue(Undefined), err
+	}
+	return c.interruptible(cancellation{ctx}, fn)
+}
+
+// Same as Eval but aborts script when ctx is cancelled or deadline exceeded,
+// in which case returned error wraps both ErrInterrupted and ctx.Err()
+func (c *Context) EvalContext(ctx context.Context, code string) (Value, error) {
+	return c.withContext(ctx, func() (Value, error) { return c.Eval(code) })
+}
+
+// Same as EvalBinary but aborts script when ctx is cancelled or deadline exceeded
+func (c *Context) EvalBinaryContext(ctx context.Context, byteCode ByteCode) (Value, error) {
+	return c.withContext(ctx, func() (Value, error) {
+		return c.EvalBinary(byteCode)
+	})
+}
+
+// Same as Call but aborts function when ctx is cancelled or deadline exceeded
+func (o Object) CallContext(ctx context.Context, this Value, args ...any) (Value, error) {
+	return o.context.withContext(ctx, func() (Value, error) {
+		return o.Call(this, args...)
+	})
+}
@@ -0,0 +1,49 @@
+package quickjs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	NewRuntime().NewContext().With(func(context *Context) {
+		_, err := context.EvalContext(ctx, "while (true) {}")
+		assert.ErrorIs(t, err, ErrInterrupted)
+		assert.ErrorIs(t, err, ctx.Err())
+
+		_, err = context.EvalContext(ctx, "1 + 1")
+		assert.ErrorIs(t, err, ErrInterrupted)
+
+		value, err := context.Eval("1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value.ToNative())
+	})
+}
+
+func TestEvalBinaryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	NewRuntime().NewContext().With(func(context *Context) {
+		byteCode, err := context.Compile("try { while (true) {} } catch (e) {}")
+		assert.NoError(t, err)
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, err = context.EvalBinaryContext(ctx, byteCode)
+		assert.True(t, errors.Is(err, ErrInterrupted))
+	})
+}
+
+func TestCallContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	NewRuntime().NewContext().With(func(context *Context) {
+		value, err := context.Eval("(function (n) { for (;;) n++ })")
+		assert.NoError(t, err)
+		_, err = value.Object().CallContext(ctx, context.ToValue(nil), 1)
+		assert.ErrorIs(t, err, ctx.Err())
+	})
+}
@@ -0,0 +1,77 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+
+type Intrinsic uint
+
+const (
+	IntrinsicDate Intrinsic = 1 << iota
+	// Without eval intrinsic, eval and Function constructor are unavailable to scripts,
+	// while Eval and Compile still work with a dedicated compiler context.
+	IntrinsicEval
+	IntrinsicStringNormalize
+	IntrinsicRegExp
+	IntrinsicJSON
+	IntrinsicProxy
+	IntrinsicMapSet
+	IntrinsicTypedArrays
+	IntrinsicPromise
+	IntrinsicBigInt
+	IntrinsicBigFloat
+	IntrinsicBigDecimal
+	// Operator overloading
+	IntrinsicOperators
+	// Enable "use math" and bigint mode, where 1/3 is not ordinary ES2020 division
+	IntrinsicBignumExt
+
+	// Intrinsics defined by ES2020
+	IntrinsicStandard = IntrinsicDate | IntrinsicEval | IntrinsicStringNormalize |
+		IntrinsicRegExp | IntrinsicJSON | IntrinsicProxy | IntrinsicMapSet |
+		IntrinsicTypedArrays | IntrinsicPromise | IntrinsicBigInt
+	// Intrinsics enabled by NewContext
+	IntrinsicAll = IntrinsicStandard | IntrinsicBigFloat | IntrinsicBigDecimal |
+		IntrinsicOperators | IntrinsicBignumExt
+)
+
+var intrinsics = [...]func(*C.JSContext){
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicDate(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicEval(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicStringNormalize(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicRegExp(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicJSON(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicProxy(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicMapSet(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicTypedArrays(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicPromise(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicBigInt(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicBigFloat(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicBigDecimal(ctx) },
+	func(ctx *C.JSContext) { C.JS_AddIntrinsicOperators(ctx) },
+	func(ctx *C.JSContext) { C.JS_EnableBignumExt(ctx, C.int(1)) },
+}
+
+func newRawContext(runtime *C.JSRuntime, intrinsic Intrinsic) *C.JSContext {
+	jsContext := C.JS_NewContextRaw(runtime)
+	C.JS_AddIntrinsicBaseObjects(jsContext)
+	for i, add := range intrinsics {
+		if intrinsic&(1<<i) != 0 {
+			add(jsContext)
+		}
+	}
+	return jsContext
+}
+
+// Compiler for context without eval intrinsic, which is able to compile
+// but not visible to scripts
+func newCompiler(runtime *C.JSRuntime, intrinsic Intrinsic) *C.JSContext {
+	compiler := newRawContext(runtime, IntrinsicEval|intrinsic&IntrinsicBignumExt)
+	if intrinsic&IntrinsicRegExp != 0 {
+		C.JS_AddIntrinsicRegExpCompiler(compiler)
+	}
+	return compiler
+}
+
+type ContextOptions struct {
+	Intrinsics Intrinsic // Base objects are always available
+}
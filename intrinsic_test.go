@@ -0,0 +1,67 @@
+package quickjs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewContextWithOptions(t *testing.T) {
+	runtime := NewRuntime()
+	options := ContextOptions{Intrinsics: IntrinsicStandard &^ IntrinsicEval}
+	runtime.NewContextWithOptions(options).With(func(context *Context) {
+		value, err := context.Eval("1 / 3")
+		assert.NoError(t, err)
+		assert.Equal(t, 1.0/3, value.ToNative())
+
+		value, err = context.Eval("typeof eval")
+		assert.NoError(t, err)
+		assert.Equal(t, "undefined", value.ToNative())
+
+		_, err = context.Eval("new Function('return 1')()")
+		assert.Error(t, err)
+
+		value, err = context.Eval("/a+/.test('caab') && JSON.stringify(new Map().size)")
+		assert.NoError(t, err)
+		assert.Equal(t, "0", value.ToNative())
+
+		_, err = context.Eval("1 +")
+		assert.Error(t, err)
+
+		byteCode, err := context.Compile("[1, 2].map(x => x * 2)")
+		assert.NoError(t, err)
+		value, err = context.EvalBinary(byteCode)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{2, 4}, value.ToNative())
+	})
+
+	options = ContextOptions{Intrinsics: IntrinsicEval}
+	runtime.NewContextWithOptions(options).With(func(context *Context) {
+		value, err := context.Eval("typeof Map + typeof JSON")
+		assert.NoError(t, err)
+		assert.Equal(t, "undefinedundefined", value.ToNative())
+	})
+}
+
+func TestCompilerException(t *testing.T) {
+	runtime := NewRuntime()
+	files := fstest.MapFS{"broken.js": {Data: []byte("export const = 1")}}
+	runtime.SetModuleLoader(FSLoader{files})
+	options := ContextOptions{Intrinsics: IntrinsicStandard &^ IntrinsicEval}
+	runtime.NewContextWithOptions(options).With(func(context *Context) {
+		_, err := context.EvalModule(`import "./missing.js"`)
+		var jsError *Error
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "InternalError", jsError.Name)
+		assert.Contains(t, jsError.Message, "missing.js")
+
+		_, err = context.EvalModule(`import "./broken.js"`)
+		assert.ErrorAs(t, err, &jsError)
+		assert.Equal(t, "SyntaxError", jsError.Name)
+
+		value, err := context.Eval("1 + 1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value.ToNative())
+	})
+}
@@ -1,6 +1,8 @@
 package quickjs
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"testing"
 
@@ -72,8 +74,11 @@ func (b *bigInt) IndexCall(index int, call Call) (Value, error) {
 		b.Add(&b.Int, big.NewInt(int64(rhs.(int))))
 		return call.ToObject(b), nil
 	case 1:
-		rhs := call.Arg(0).ToNative()
-		b.Sub(&b.Int, big.NewInt(int64(rhs.(int))))
+		rhs, ok := call.Arg(0).ToNative().(int)
+		if !ok {
+			return call.ToValue(nil), TypeError("%v is not an integer", call.Arg(0))
+		}
+		b.Sub(&b.Int, big.NewInt(int64(rhs)))
 		return call.ToObject(b), nil
 	case 2:
 		return call.ToValue(nil), nil
@@ -95,6 +100,42 @@ func TestIndexCall(t *testing.T) {
 		value, err = context.Eval(`ut.sub(2n)`)
 		assert.NoError(t, err)
 		assert.Equal(t, &bigInt{*big.NewInt(100)}, value.ToNative())
+
+		value, err = context.Eval(`try { ut.sub("2") } catch (e) { e instanceof TypeError }`)
+		assert.NoError(t, err)
+		assert.Equal(t, true, value.ToNative())
+	})
+}
+
+func TestThrowError(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		var thrown error
+		context.GlobalObject().SetFunc("fail", func(call Call) (Value, error) {
+			return call.ToValue(Undefined), thrown
+		})
+		check := `try { fail() } catch (e) { e instanceof Error ? [e.name, e.message] : e }`
+		for _, testCase := range []struct {
+			thrown   error
+			expected any
+		}{
+			{TypeError("expect %s", "string"), []any{"TypeError", "expect string"}},
+			{RangeError("too large"), []any{"RangeError", "too large"}},
+			{SyntaxError("bad"), []any{"SyntaxError", "bad"}},
+			{ReferenceError("missing"), []any{"ReferenceError", "missing"}},
+			{Throw(map[string]any{"code": 1}), map[string]any{"code": 1}},
+			{Throw(nil), nil},
+			{fmt.Errorf("wrapped: %w", RangeError("limit")), []any{"RangeError", "wrapped: limit"}},
+			{errors.New("plain"), []any{"InternalError", "plain"}},
+		} {
+			thrown = testCase.thrown
+			value, err := context.Eval(check)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, value.ToNative())
+		}
+
+		thrown = RangeError("out of range")
+		_, err := context.Eval(`fail()`)
+		assert.Equal(t, "RangeError: out of range", err.Error())
 	})
 }
 
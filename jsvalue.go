@@ -53,8 +53,9 @@ func (c *Context) toValue(value any) C.JSValue {
 		return C.JS_NewFloat64(c.raw, C.double(value))
 	case big.Int:
 		arg := C.JS_NewString(c.raw, strPtr(value.String()+"\x00"))
-		bigInt, _ := c.GlobalObject().GetProperty("BigInt")
-		retval := bigInt.Object().call(null, 1, &arg)
+		bigInt := c.GlobalObject().getProperty("BigInt")
+		retval := Value{c, bigInt}.Object().call(null, 1, &arg)
+		C.JS_FreeValue(c.raw, bigInt)
 		C.JS_FreeValue(c.raw, arg)
 		return retval
 	case string:
@@ -89,7 +90,7 @@ func (c *Context) toValue(value any) C.JSValue {
 		}
 		return object.raw
 	case Value:
-		return value.raw
+		return C.JS_DupValue(c.raw, value.raw)
 	case json.Marshaler:
 		var buf bytes.Buffer
 		if err := json.NewEncoder(&buf).Encode(value); err != nil {
@@ -112,7 +113,7 @@ func (c *Context) toValue(value any) C.JSValue {
 		}
 		switch valueOf.Kind() {
 		case reflect.Map:
-			class, _ := c.GlobalObject().GetProperty("Map")
+			class := c.GlobalObject().getProperty("Map")
 			items := make([]any, 0, valueOf.Len())
 			iter := valueOf.MapRange()
 			for iter.Next() {
@@ -120,8 +121,9 @@ func (c *Context) toValue(value any) C.JSValue {
 				items = append(items, item)
 			}
 			jsValue := c.toValue(items)
-			jsMap := C.JS_CallConstructor(c.raw, class.raw, 1, &jsValue)
+			jsMap := C.JS_CallConstructor(c.raw, class, 1, &jsValue)
 			C.JS_FreeValue(c.raw, jsValue)
+			C.JS_FreeValue(c.raw, class)
 			return jsMap
 		case reflect.Array, reflect.Slice:
 			array := Value{c, C.JS_NewArray(c.raw)}.Object().Array()
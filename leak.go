@@ -0,0 +1,90 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Resources not released on Context.Free or Runtime.Free in debug mode
+type LeakReport struct {
+	Context  bool  // Reported by Context.Free, otherwise by Runtime.Free
+	GoValues []any // Go values still referenced by JS objects of context
+	Handles  int   // References owned by Value.Dup or Object.Call but never released
+	Objects  int64 // JS objects and functions remain after all contexts are freed
+	Atoms    int64 // Atoms remain after all contexts are freed
+}
+
+func (r LeakReport) Leaked() bool {
+	return len(r.GoValues) > 0 || r.Handles > 0 || r.Objects > 0 || r.Atoms > 0
+}
+
+func (r LeakReport) String() string {
+	var buf strings.Builder
+	if r.Context {
+		buf.WriteString("context leaks:")
+	} else {
+		buf.WriteString("runtime leaks:")
+	}
+	if len(r.GoValues) > 0 {
+		types := make([]string, len(r.GoValues))
+		for i, value := range r.GoValues {
+			types[i] = fmt.Sprintf("%T", value)
+		}
+		fmt.Fprintf(&buf, " %d go values (%s)", len(types), strings.Join(types, ", "))
+	}
+	if r.Handles > 0 {
+		fmt.Fprintf(&buf, " %d handles", r.Handles)
+	}
+	if r.Objects > 0 {
+		fmt.Fprintf(&buf, " %d objects", r.Objects)
+	}
+	if r.Atoms > 0 {
+		fmt.Fprintf(&buf, " %d atoms", r.Atoms)
+	}
+	return buf.String()
+}
+
+// Satisfied by testing.TB
+type errorReporter interface {
+	Errorf(format string, args ...any)
+}
+
+// Leak handler failing test, e.g. Config{Debug: true, LeakHandler: FailOnLeak(t)}
+func FailOnLeak(t errorReporter) func(LeakReport) {
+	return func(report LeakReport) { t.Errorf("%s", report) }
+}
+
+func (r *Runtime) reportLeak(report LeakReport) {
+	if !report.Leaked() {
+		return
+	}
+	if r.leakHandler != nil {
+		r.leakHandler(report)
+		return
+	}
+	log.Printf("quickjs: %s", report)
+}
+
+// Number of handles must be counted before they are released
+func (c *Context) checkLeaks(handles int) {
+	C.JS_RunGC(c.runtime.raw) // Collect cycles of freed context
+	report := LeakReport{Context: true, Handles: handles}
+	for _, value := range c.goValues {
+		report.GoValues = append(report.GoValues, value)
+	}
+	c.runtime.reportLeak(report)
+}
+
+// Return false if runtime must not be freed, since quickjs asserts
+// no object remains
+func (r *Runtime) checkLeaks() bool {
+	C.JS_RunGC(r.raw)
+	usage := r.GetMemoryUsage()
+	report := LeakReport{Objects: usage.ObjCount + usage.JsFuncCount}
+	report.Atoms = usage.AtomCount - r.atomCount
+	r.reportLeak(report)
+	return report.Objects == 0
+}
@@ -0,0 +1,44 @@
+package quickjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type leaked struct{ Name string }
+
+func TestLeakReport(t *testing.T) {
+	config := Config{ManualFree: true, Debug: true, LeakHandler: FailOnLeak(t)}
+	runtime := NewRuntime(config)
+	guard := runtime.NewContext()
+	guard.With(func(context *Context) {
+		context.GlobalObject().SetFunc("add", func(call Call) (Value, error) {
+			return call.ToValue(call.Arg(0).ToNative().(int) + 1), nil
+		})
+		value, err := context.Eval(`({ result: add(1) })`)
+		assert.NoError(t, err)
+		value.Dup().Free()
+	})
+	guard.Free()
+	runtime.Free()
+
+	var reports []LeakReport
+	config.LeakHandler = func(report LeakReport) { reports = append(reports, report) }
+	runtime = NewRuntime(config)
+	guard = runtime.NewContext()
+	guard.With(func(context *Context) {
+		context.ToObject(&leaked{"object"})
+		value, _ := context.Eval(`({})`)
+		value.Dup()
+	})
+	guard.Free()
+	runtime.Free()
+	assert.Len(t, reports, 2)
+	assert.True(t, reports[0].Context)
+	assert.Equal(t, 1, reports[0].Handles)
+	assert.Equal(t, []any{&leaked{"object"}}, reports[0].GoValues)
+	assert.False(t, reports[1].Context)
+	assert.Greater(t, reports[1].Objects, int64(0)) // Including realm of leaked object
+	assert.Contains(t, reports[0].String(), "1 go values (*quickjs.leaked) 1 handles")
+}
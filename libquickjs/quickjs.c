@@ -270,6 +270,8 @@ struct JSRuntime {
     JSValue current_exception;
     /* true if inside an out of memory error, to avoid recursing */
     BOOL in_out_of_memory : 8;
+    /* last value thrown by JS_ThrowOutOfMemory() */
+    JSValue out_of_memory_error;
 
     struct JSStackFrame *current_stack_frame;
 
@@ -1682,6 +1684,7 @@ JSRuntime *JS_NewRuntime2(const JSMallocFunctions *mf, void *opaque)
     JS_UpdateStackTop(rt);
 
     rt->current_exception = JS_UNINITIALIZED;
+    rt->out_of_memory_error = JS_UNDEFINED;
 
     return rt;
  fail:
@@ -1945,6 +1948,7 @@ void JS_FreeRuntime(JSRuntime *rt)
     int i;
 
     JS_FreeValueRT(rt, rt->current_exception);
+    JS_FreeValueRT(rt, rt->out_of_memory_error);
 
     list_for_each_safe(el, el1, &rt->job_list) {
         JSJobEntry *e = list_entry(el, JSJobEntry, link);
@@ -6383,6 +6387,13 @@ JSValue JS_GetGlobalObject(JSContext *ctx)
     return JS_DupValue(ctx, ctx->global_obj);
 }
 
+/* object holding global lexical variables, i.e. top level let, const and
+   class declarations */
+JSValue JS_GetGlobalVarObject(JSContext *ctx)
+{
+    return JS_DupValue(ctx, ctx->global_var_obj);
+}
+
 /* WARNING: obj is freed */
 JSValue JS_Throw(JSContext *ctx, JSValue obj)
 {
@@ -6778,11 +6789,29 @@ JSValue JS_ThrowOutOfMemory(JSContext *ctx)
     if (!rt->in_out_of_memory) {
         rt->in_out_of_memory = TRUE;
         JS_ThrowInternalError(ctx, "out of memory");
+        JS_FreeValueRT(rt, rt->out_of_memory_error);
+        rt->out_of_memory_error = JS_DupValueRT(rt, rt->current_exception);
         rt->in_out_of_memory = FALSE;
     }
     return JS_EXCEPTION;
 }
 
+/* return TRUE if 'val' is the last value thrown by JS_ThrowOutOfMemory(),
+   which is forgotten in any case */
+JS_BOOL JS_TakeOutOfMemoryError(JSRuntime *rt, JSValueConst val)
+{
+    JSValue error = rt->out_of_memory_error;
+    JS_BOOL ret;
+
+    ret = JS_VALUE_GET_TAG(error) != JS_TAG_UNDEFINED &&
+        JS_VALUE_GET_TAG(error) == JS_VALUE_GET_TAG(val) &&
+        (!JS_VALUE_HAS_REF_COUNT(val) ||
+         JS_VALUE_GET_PTR(error) == JS_VALUE_GET_PTR(val));
+    JS_FreeValueRT(rt, error);
+    rt->out_of_memory_error = JS_UNDEFINED;
+    return ret;
+}
+
 static JSValue JS_ThrowStackOverflow(JSContext *ctx)
 {
     return JS_ThrowInternalError(ctx, "stack overflow");
@@ -6860,6 +6889,12 @@ static inline __exception int js_poll_interrupts(JSContext *ctx)
     }
 }
 
+/* restart the countdown to the next call of the interrupt handler */
+void JS_ResetInterruptCounter(JSContext *ctx)
+{
+    ctx->interrupt_counter = JS_INTERRUPT_COUNTER_INIT;
+}
+
 /* return -1 (exception) or TRUE/FALSE */
 static int JS_SetPrototypeInternal(JSContext *ctx, JSValueConst obj,
                                    JSValueConst proto_val,
@@ -20241,6 +20276,11 @@ int __attribute__((format(printf, 2, 3))) js_parse_error(JSParseState *s, const
     va_list ap;
     int backtrace_flags;
 
+    /* parsing went astray because emitting byte code failed */
+    if (s->cur_func && dbuf_error(&s->cur_func->byte_code)) {
+        JS_ThrowOutOfMemory(ctx);
+        return -1;
+    }
     va_start(ap, fmt);
     JS_ThrowError2(ctx, JS_SYNTAX_ERROR, fmt, ap, FALSE);
     va_end(ap);
@@ -21667,8 +21707,11 @@ static int new_label_fd(JSFunctionDef *fd, int label)
     if (label < 0) {
         if (js_resize_array(fd->ctx, (void *)&fd->label_slots,
                             sizeof(fd->label_slots[0]),
-                            &fd->label_size, fd->label_count + 1))
+                            &fd->label_size, fd->label_count + 1)) {
+            /* checked by js_create_function() */
+            dbuf_set_error(&fd->byte_code);
             return -1;
+        }
         label = fd->label_count++;
         ls = &fd->label_slots[label];
         ls->ref_count = 0;
@@ -21688,6 +21731,8 @@ static int new_label(JSParseState *s)
 /* don't update the last opcode and don't emit line number info */
 static void emit_label_raw(JSParseState *s, int label)
 {
+    if (label < 0)
+        return;
     emit_u8(s, OP_label);
     emit_u32(s, label);
     s->cur_func->label_slots[label].pos = s->cur_func->byte_code.size;
@@ -21712,6 +21757,8 @@ static int emit_goto(JSParseState *s, int opcode, int label)
     if (js_is_live_code(s)) {
         if (label < 0)
             label = new_label(s);
+        if (label < 0)
+            return -1;
         emit_op(s, opcode);
         emit_u32(s, label);
         s->cur_func->label_slots[label].ref_count++;
@@ -23729,7 +23776,8 @@ static __exception int get_lvalue(JSParseState *s, int *popcode, int *pscope,
             emit_atom(s, name);
             emit_u32(s, label);
             emit_u16(s, scope);
-            update_label(fd, label, 1);
+            if (label >= 0)
+                update_label(fd, label, 1);
             emit_op(s, OP_get_ref_value);
             opcode = OP_get_ref_value;
             break;
@@ -23764,7 +23812,8 @@ static __exception int get_lvalue(JSParseState *s, int *popcode, int *pscope,
             emit_atom(s, name);
             emit_u32(s, label);
             emit_u16(s, scope);
-            update_label(fd, label, 1);
+            if (label >= 0)
+                update_label(fd, label, 1);
             opcode = OP_get_ref_value;
             break;
         case OP_get_array_el:
@@ -24433,7 +24482,8 @@ static int js_parse_destructuring_element(JSParseState *s, int tok, int is_arg,
         /* remove test and decrement label ref count */
         memset(s->cur_func->byte_code.buf + start_addr, OP_nop,
                assign_addr - start_addr);
-        s->cur_func->label_slots[label_parse].ref_count--;
+        if (label_parse >= 0)
+            s->cur_func->label_slots[label_parse].ref_count--;
         has_initializer = FALSE;
     }
     return has_initializer;
@@ -26998,7 +27048,7 @@ static __exception int js_parse_statement_or_decl(JSParseState *s,
             }
             if (js_parse_expect(s, '}'))
                 goto fail;
-            if (default_label_pos >= 0) {
+            if (default_label_pos >= 0 && label_case >= 0) {
                 /* Ugly patch for the the `default` label, shameful and risky */
                 put_u32(s->cur_func->byte_code.buf + default_label_pos,
                         label_case);
@@ -28917,6 +28967,8 @@ static int js_execute_sync_module(JSContext *ctx, JSModuleDef *m,
         if (state == JS_PROMISE_FULFILLED) {
             JS_FreeValue(ctx, promise);
         } else if (state == JS_PROMISE_REJECTED) {
+            /* the exception is rethrown by the module evaluation */
+            JS_MarkPromiseHandled(ctx, promise);
             *pvalue = JS_PromiseResult(ctx, promise);
             JS_FreeValue(ctx, promise);
             return -1;
@@ -30327,6 +30379,10 @@ static void var_object_test(JSContext *ctx, JSFunctionDef *s,
     *plabel_done = new_label_fd(s, *plabel_done);
     dbuf_put_u32(bc, *plabel_done);
     dbuf_putc(bc, is_with);
+    if (*plabel_done < 0) {
+        dbuf_set_error(bc);
+        return;
+    }
     update_label(s, *plabel_done, 1);
     s->jump_size++;
 }
@@ -31375,7 +31431,10 @@ static void instantiate_hoisted_definitions(JSContext *ctx, JSFunctionDef *s, Dy
         dbuf_putc(bc, OP_push_this);
         dbuf_putc(bc, OP_if_false);
         dbuf_put_u32(bc, label_next);
-        update_label(s, label_next, 1);
+        if (label_next >= 0)
+            update_label(s, label_next, 1);
+        else
+            dbuf_set_error(bc);
         s->jump_size++;
     }
 
@@ -31466,7 +31525,8 @@ static void instantiate_hoisted_definitions(JSContext *ctx, JSFunctionDef *s, Dy
 
         dbuf_putc(bc, OP_label);
         dbuf_put_u32(bc, label_next);
-        s->label_slots[label_next].pos2 = bc->size;
+        if (label_next >= 0)
+            s->label_slots[label_next].pos2 = bc->size;
     }
 
     js_free(ctx, s->global_vars);
@@ -33310,6 +33370,11 @@ static JSValue js_create_function(JSContext *ctx, JSFunctionDef *fd)
     int function_size, byte_code_offset, cpool_offset;
     int closure_var_offset, vardefs_offset;
 
+    if (dbuf_error(&fd->byte_code)) {
+        JS_ThrowOutOfMemory(ctx);
+        goto fail;
+    }
+
     /* recompute scope linkage */
     for (scope = 0; scope < fd->scope_count; scope++) {
         fd->scopes[scope].first = -1;
@@ -34376,16 +34441,16 @@ static __exception int js_parse_program(JSParseState *s)
 
 static void js_parse_init(JSContext *ctx, JSParseState *s,
                           const char *input, size_t input_len,
-                          const char *filename)
+                          const char *filename, int line_num)
 {
     memset(s, 0, sizeof(*s));
     s->ctx = ctx;
     s->filename = filename;
-    s->line_num = 1;
+    s->line_num = line_num;
     s->buf_ptr = (const uint8_t *)input;
     s->buf_end = s->buf_ptr + input_len;
     s->token.val = ' ';
-    s->token.line_num = 1;
+    s->token.line_num = line_num;
 }
 
 static JSValue JS_EvalFunctionInternal(JSContext *ctx, JSValue fun_obj,
@@ -34426,9 +34491,10 @@ JSValue JS_EvalFunction(JSContext *ctx, JSValue fun_obj)
 }
 
 /* 'input' must be zero terminated i.e. input[input_len] = '\0'. */
-static JSValue __JS_EvalInternal(JSContext *ctx, JSValueConst this_obj,
-                                 const char *input, size_t input_len,
-                                 const char *filename, int flags, int scope_idx)
+static JSValue __JS_EvalLine(JSContext *ctx, JSValueConst this_obj,
+                             const char *input, size_t input_len,
+                             const char *filename, int line_num,
+                             int flags, int scope_idx)
 {
     JSParseState s1, *s = &s1;
     int err, js_mode, eval_type;
@@ -34439,7 +34505,7 @@ static JSValue __JS_EvalInternal(JSContext *ctx, JSValueConst this_obj,
     JSFunctionDef *fd;
     JSModuleDef *m;
 
-    js_parse_init(ctx, s, input, input_len, filename);
+    js_parse_init(ctx, s, input, input_len, filename, line_num);
     skip_shebang(&s->buf_ptr, s->buf_end);
 
     eval_type = flags & JS_EVAL_TYPE_MASK;
@@ -34544,6 +34610,14 @@ static JSValue __JS_EvalInternal(JSContext *ctx, JSValueConst this_obj,
 }
 
 /* the indirection is needed to make 'eval' optional */
+static JSValue __JS_EvalInternal(JSContext *ctx, JSValueConst this_obj,
+                                 const char *input, size_t input_len,
+                                 const char *filename, int flags, int scope_idx)
+{
+    return __JS_EvalLine(ctx, this_obj, input, input_len, filename, 1,
+                         flags, scope_idx);
+}
+
 static JSValue JS_EvalInternal(JSContext *ctx, JSValueConst this_obj,
                                const char *input, size_t input_len,
                                const char *filename, int flags, int scope_idx)
@@ -34594,6 +34668,21 @@ JSValue JS_Eval(JSContext *ctx, const char *input, size_t input_len,
                        eval_flags);
 }
 
+/* same as JS_Eval() but the first line of 'input' is numbered 'line_num' */
+JSValue JS_EvalLine(JSContext *ctx, const char *input, size_t input_len,
+                    const char *filename, int line_num, int eval_flags)
+{
+    int eval_type = eval_flags & JS_EVAL_TYPE_MASK;
+
+    assert(eval_type == JS_EVAL_TYPE_GLOBAL ||
+           eval_type == JS_EVAL_TYPE_MODULE);
+    if (unlikely(!ctx->eval_internal)) {
+        return JS_ThrowTypeError(ctx, "eval is not supported");
+    }
+    return __JS_EvalLine(ctx, ctx->global_obj, input, input_len, filename,
+                         line_num, eval_flags, -1);
+}
+
 int JS_ResolveModule(JSContext *ctx, JSValueConst obj)
 {
     if (JS_VALUE_GET_TAG(obj) == JS_TAG_MODULE) {
@@ -45162,7 +45251,7 @@ JSValue JS_ParseJSON2(JSContext *ctx, const char *buf, size_t buf_len,
     JSParseState s1, *s = &s1;
     JSValue val = JS_UNDEFINED;
 
-    js_parse_init(ctx, s, buf, buf_len, filename);
+    js_parse_init(ctx, s, buf, buf_len, filename, 1);
     s->ext_json = ((flags & JS_PARSE_JSON_EXT) != 0);
     if (json_next_token(s))
         goto fail;
@@ -47922,6 +48011,22 @@ JSValue JS_PromiseResult(JSContext *ctx, JSValue promise)
     return JS_DupValue(ctx, s->promise_result);
 }
 
+/* tell the rejection tracker that the rejection of 'promise' is handled,
+   e.g. because its result is consumed by the host */
+void JS_MarkPromiseHandled(JSContext *ctx, JSValueConst promise)
+{
+    JSPromiseData *s = JS_GetOpaque(promise, JS_CLASS_PROMISE);
+    JSRuntime *rt = ctx->rt;
+    if (!s || s->is_handled)
+        return;
+    if (s->promise_state == JS_PROMISE_REJECTED &&
+        rt->host_promise_rejection_tracker) {
+        rt->host_promise_rejection_tracker(ctx, promise, s->promise_result,
+                                           TRUE, rt->host_promise_rejection_tracker_opaque);
+    }
+    s->is_handled = TRUE;
+}
+
 static int js_create_resolving_functions(JSContext *ctx, JSValue *args,
                                          JSValueConst promise);
 
@@ -6,3 +6,16 @@ package libquickjs
 #cgo LDFLAGS: -lm
 */
 import "C"
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed VERSION
+var version string
+
+// Release of vendored QuickJS, copied from upstream by update.sh
+var Version = strings.TrimSpace(version)
+
+// Whether QuickJS is built with CONFIG_BIGNUM, which changes bytecode format
+const BigNum = true
@@ -644,6 +644,9 @@ JSValue __js_printf_like(2, 3) JS_ThrowReferenceError(JSContext *ctx, const char
 JSValue __js_printf_like(2, 3) JS_ThrowRangeError(JSContext *ctx, const char *fmt, ...);
 JSValue __js_printf_like(2, 3) JS_ThrowInternalError(JSContext *ctx, const char *fmt, ...);
 JSValue JS_ThrowOutOfMemory(JSContext *ctx);
+/* return TRUE if 'val' is the last value thrown by JS_ThrowOutOfMemory(),
+   which is forgotten in any case */
+JS_BOOL JS_TakeOutOfMemoryError(JSRuntime *rt, JSValueConst val);
 
 void __JS_FreeValue(JSContext *ctx, JSValue v);
 static inline void JS_FreeValue(JSContext *ctx, JSValue v)
@@ -796,7 +799,13 @@ JSValue JS_Eval(JSContext *ctx, const char *input, size_t input_len,
 JSValue JS_EvalThis(JSContext *ctx, JSValueConst this_obj,
                     const char *input, size_t input_len,
                     const char *filename, int eval_flags);
+/* same as JS_Eval() but the first line of 'input' is numbered 'line_num' */
+JSValue JS_EvalLine(JSContext *ctx, const char *input, size_t input_len,
+                    const char *filename, int line_num, int eval_flags);
 JSValue JS_GetGlobalObject(JSContext *ctx);
+/* object holding global lexical variables, i.e. top level let, const and
+   class declarations */
+JSValue JS_GetGlobalVarObject(JSContext *ctx);
 int JS_IsInstanceOf(JSContext *ctx, JSValueConst val, JSValueConst obj);
 int JS_DefineProperty(JSContext *ctx, JSValueConst this_obj,
                       JSAtom prop, JSValueConst val,
@@ -869,6 +878,9 @@ typedef enum JSPromiseStateEnum {
 JSValue JS_NewPromiseCapability(JSContext *ctx, JSValue *resolving_funcs);
 JSPromiseStateEnum JS_PromiseState(JSContext *ctx, JSValue promise);
 JSValue JS_PromiseResult(JSContext *ctx, JSValue promise);
+/* tell the rejection tracker that the rejection of 'promise' is handled,
+   e.g. because its result is consumed by the host */
+void JS_MarkPromiseHandled(JSContext *ctx, JSValueConst promise);
 
 /* is_handled = TRUE means that the rejection is handled */
 typedef void JSHostPromiseRejectionTracker(JSContext *ctx, JSValueConst promise,
@@ -879,6 +891,8 @@ void JS_SetHostPromiseRejectionTracker(JSRuntime *rt, JSHostPromiseRejectionTrac
 /* return != 0 if the JS code needs to be interrupted */
 typedef int JSInterruptHandler(JSRuntime *rt, void *opaque);
 void JS_SetInterruptHandler(JSRuntime *rt, JSInterruptHandler *cb, void *opaque);
+/* restart the countdown to the next call of the interrupt handler */
+void JS_ResetInterruptCounter(JSContext *ctx);
 /* if can_block is TRUE, Atomics.wait() can be used */
 void JS_SetCanBlock(JSRuntime *rt, JS_BOOL can_block);
 /* set the [IsHTMLDDA] internal slot */
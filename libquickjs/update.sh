@@ -12,7 +12,10 @@ git clone --depth=1 $REPO $tempdir || {
 for name in $(ls -1 .); do
     test -f $tempdir/$name && cp $tempdir/$name .
 done
+for patch in *.patch; do
+    patch -p1 < $patch || error "Apply $patch failed"
+done
 version=$(<$tempdir/VERSION)
-sed -i "s/CONFIG_VERSION/$verison/g" quickjs.c
+sed -i "s/CONFIG_VERSION/\"$version\"/g" quickjs.c
 rm -rf $tempdir
 cd -
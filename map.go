@@ -6,14 +6,11 @@ import "C"
 type Map struct{ Object }
 
 func (m Map) Size() int {
-	property, _ := m.GetProperty("size")
-	return property.ToPrimitive().(int)
+	return m.context.consumeNative(m.getProperty("size")).(int)
 }
 
 func (m Map) ToNative() map[any]any {
-	arrayFn, _ := m.context.GlobalObject().GetProperty("Array")
-	from, _ := arrayFn.Object().GetProperty("from")
-	callValue := m.context.assert(from.Object().call(null, 1, &m.raw))
+	callValue := m.context.assert(m.context.arrayFrom(m.raw))
 	array := Value{m.context, callValue}.Object().Array()
 	length := array.Len()
 	if length == 0 {
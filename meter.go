@@ -0,0 +1,48 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+
+// Result of metered evaluation.
+//
+// Units counts invocations of quickjs interrupt handler, which is polled once
+// per about 10000 backward jumps or function calls. The countdown restarts for
+// each metered evaluation, so units are independent of wall-clock and of
+// earlier evaluations.
+type Metered struct {
+	Value
+	Units uint64
+}
+
+type meter struct{ units, budget uint64 }
+
+func (m *meter) interrupt() error {
+	m.units++
+	if m.budget > 0 && m.units > m.budget {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+func (c *Context) metered(budget uint64, fn func() (Value, error)) (Metered, error) {
+	meter := &meter{budget: budget}
+	C.JS_ResetInterruptCounter(c.raw)
+	value, err := c.interruptible(meter, fn)
+	return Metered{value, meter.units}, err
+}
+
+// Evaluate code and count units consumed, use 0 budget for unlimited.
+// ErrBudgetExceeded is returned once units consumed exceeds budget.
+func (c *Context) EvalMetered(code string, budget uint64) (Metered, error) {
+	return c.metered(budget, func() (Value, error) { return c.Eval(code) })
+}
+
+// Same as EvalMetered but evaluate ByteCode
+func (c *Context) EvalBinaryMetered(byteCode ByteCode, budget uint64) (Metered, error) {
+	return c.metered(budget, func() (Value, error) { return c.EvalBinary(byteCode) })
+}
+
+// Call function and count units consumed, use 0 budget for unlimited.
+func (o Object) CallMetered(budget uint64, this Value, args ...any) (Metered, error) {
+	return o.context.metered(budget, func() (Value, error) { return o.Call(this, args...) })
+}
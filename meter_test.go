@@ -0,0 +1,46 @@
+package quickjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalMetered(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		metered, err := context.EvalMetered("1 + 1", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, metered.ToNative())
+		assert.LessOrEqual(t, metered.Units, uint64(1))
+
+		code := "let n = 0; for (let i = 0; i < 100000; i++) n++; n"
+		metered, err = context.EvalMetered(code, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 100000, metered.ToNative())
+		assert.Greater(t, metered.Units, uint64(1))
+
+		// Same units regardless of earlier evaluations
+		code = "for (let i = 0; i < 4000; i++);"
+		first, err := context.EvalMetered(code, 0)
+		assert.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			metered, err = context.EvalMetered(code, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, first.Units, metered.Units)
+		}
+
+		metered, err = context.EvalMetered("while (true) {}", 3)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+		assert.Equal(t, uint64(4), metered.Units)
+	})
+}
+
+func TestCallMetered(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		value, err := context.Eval("(function (n) { for (;;) n++ })")
+		assert.NoError(t, err)
+		metered, err := value.Object().CallMetered(1, context.ToValue(nil), 1)
+		assert.ErrorIs(t, err, ErrBudgetExceeded)
+		assert.Equal(t, uint64(2), metered.Units)
+	})
+}
@@ -0,0 +1,189 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Either Source or ByteCode compiled as module
+type ModuleCode struct {
+	Source   string
+	ByteCode ByteCode
+}
+
+type ModuleLoader interface {
+	// Resolve specifier imported by module base to module name
+	Resolve(base, specifier string) (string, error)
+	// Load module with resolved name
+	Load(name string) (ModuleCode, error)
+}
+
+// Install loader for importing modules, nil restores default loader
+// which is only able to import modules already loaded.
+func (r *Runtime) SetModuleLoader(loader ModuleLoader) {
+	r.loader = loader
+	if loader == nil {
+		C.SetModuleLoader(r.raw, nil)
+		return
+	}
+	C.SetModuleLoader(r.raw, r.data)
+}
+
+// Compile module with specified name in raw context, which is either
+// context itself or its compiler, throws and returns nil on error
+func (c *Context) compileModule(raw *C.JSContext, name string, code ModuleCode) *C.JSModuleDef {
+	var module C.JSValue
+	if code.ByteCode != nil {
+		byteCode, err := code.ByteCode.payload()
+		if err != nil {
+			throwInternalError(raw, "%s: %s", name, err)
+			return nil
+		}
+		flags := C.int(C.JS_READ_OBJ_BYTECODE)
+		module = C.JS_ReadObject(raw, bytesPtr(byteCode), C.size_t(len(byteCode)), flags)
+	} else {
+		flags := C.int(C.JS_EVAL_TYPE_MODULE | C.JS_EVAL_FLAG_COMPILE_ONLY)
+		source, filename := code.Source, name+"\x00"
+		if raw == c.compiler {
+			module = C.JS_Eval(raw, strPtr(source+"\x00"), strlen(source), strPtr(filename), flags)
+		} else {
+			module = c.jsEval(source, name, 1, flags)
+		}
+	}
+	if C.JS_IsException(module) == 1 {
+		return nil
+	}
+	if C.JS_IsModule(module) == 0 {
+		C.JS_FreeValue(raw, module)
+		throwInternalError(raw, "%s is not a module", name)
+		return nil
+	}
+	moduleDef := (*C.JSModuleDef)(C.JS_ValuePtr(module))
+	C.JS_FreeValue(raw, module) // Still referenced by loaded modules of context
+	return moduleDef
+}
+
+// Module loader backed by fs.FS, files with .jsc extension are loaded as ByteCode.
+//
+// Specifiers starting with ./ or ../ are resolved relative to importing module,
+// others are resolved relative to root of FS.
+type FSLoader struct{ fs.FS }
+
+func (l FSLoader) Resolve(base, specifier string) (string, error) {
+	if strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../") {
+		return path.Join(path.Dir(base), specifier), nil
+	}
+	return path.Clean(strings.TrimPrefix(specifier, "/")), nil
+}
+
+func (l FSLoader) Load(name string) (ModuleCode, error) {
+	data, err := fs.ReadFile(l.FS, name)
+	if err != nil {
+		return ModuleCode{}, err
+	}
+	if path.Ext(name) == ".jsc" {
+		return ModuleCode{ByteCode: data}, nil
+	}
+	return ModuleCode{Source: string(data)}, nil
+}
+
+func (c *Context) toExport(value any) C.JSValue {
+	if fn, ok := value.(Func); ok {
+		return c.rawFunc(fn)
+	}
+	return c.toValue(value)
+}
+
+func newModule(raw *C.JSContext, name string, exports map[string]any) *C.JSModuleDef {
+	module := C.NewModule(raw, strPtr(name+"\x00"))
+	if module == nil {
+		return nil
+	}
+	for exportName := range exports {
+		if C.JS_AddModuleExport(raw, module, strPtr(exportName+"\x00")) < 0 {
+			return nil
+		}
+	}
+	return module
+}
+
+// Register native module which can be imported by scripts evaluated later,
+// Func exports are exposed as functions and others are converted with ToValue.
+func (c *Context) NewModule(name string, exports map[string]any) error {
+	if c.compiler != nil && newModule(c.compiler, name, exports) == nil {
+		return c.getException()
+	}
+	module := newModule(c.raw, name, exports)
+	if module == nil {
+		return c.getException()
+	}
+	c.modules[module] = exports
+	c.nativeModules = append(c.nativeModules, name)
+	return nil
+}
+
+// Module evaluation never throws but returns a promise rejected with exception
+func (c *Context) checkPromise(promise C.JSValue) error {
+	if C.JS_PromiseState(c.raw, promise) != C.JS_PROMISE_REJECTED {
+		return nil
+	}
+	C.JS_MarkPromiseHandled(c.raw, promise) // Reported as error instead
+	C.JS_Throw(c.raw, C.JS_PromiseResult(c.raw, promise))
+	return c.getException()
+}
+
+func (c *Context) evalModule(module C.JSValue) (Object, error) {
+	moduleDef := (*C.JSModuleDef)(C.JS_ValuePtr(module))
+	C.JS_FreeValue(c.raw, c.evalRet)
+	c.evalRet = null // Evaluation may panic with Config.Repanic
+	promise := C.JS_EvalFunction(c.raw, module)
+	if err := c.checkException(promise); err != nil {
+		return Object{}, err
+	}
+	err := c.checkPromise(promise)
+	C.JS_FreeValue(c.raw, promise)
+	if err != nil {
+		return Object{}, err
+	}
+	namespace := C.JS_GetModuleNamespace(c.raw, moduleDef)
+	if err := c.checkException(namespace); err != nil {
+		return Object{}, err
+	}
+	c.evalRet = namespace
+	return Value{c, namespace}.Object(), nil
+}
+
+// Evaluate code as module and return namespace object holding its exports,
+// which must be consumed before next Eval or EvalBinary, or retained by Dup.
+func (c *Context) EvalModule(code string, options ...EvalOptions) (Object, error) {
+	var option EvalOptions
+	if len(options) > 0 {
+		option = options[0]
+	}
+	option.Type, option.CompileOnly = EvalModule, true
+	module := c.jsEval(code, option.filename(), option.line(), option.flags(code))
+	if err := c.checkException(module); err != nil {
+		return Object{}, err
+	}
+	return c.evalModule(module)
+}
+
+// Same as EvalModule but evaluate module compiled as ByteCode
+func (c *Context) EvalModuleBinary(byteCode ByteCode) (Object, error) {
+	module, err := c.readByteCode(byteCode)
+	if err != nil {
+		return Object{}, err
+	}
+	if C.JS_IsModule(module) == 0 {
+		C.JS_FreeValue(c.raw, module)
+		return Object{}, &Error{Message: "ByteCode is not a module"}
+	}
+	if C.JS_ResolveModule(c.raw, module) < 0 {
+		C.JS_FreeValue(c.raw, module)
+		return Object{}, c.getException()
+	}
+	return c.evalModule(module)
+}
@@ -0,0 +1,95 @@
+package quickjs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSLoader(t *testing.T) {
+	runtime := NewRuntime()
+	runtime.NewContext().With(func(context *Context) {
+		options := CompileOptions{EvalOptions: EvalOptions{Filename: "lib/const.js", Type: EvalModule}}
+		byteCode, err := context.CompileWithOptions("export const base = 10", options)
+		assert.NoError(t, err)
+
+		files := fstest.MapFS{
+			"util.js":       {Data: []byte(`export const double = x => x * 2`)},
+			"lib/math.js":   {Data: []byte(`export { double } from "../util.js"`)},
+			"lib/const.jsc": {Data: byteCode},
+		}
+		runtime.SetModuleLoader(FSLoader{files})
+		code := `
+			import { double } from "./lib/math.js"
+			import { base } from "/lib/const.jsc"
+			globalThis.result = double(base)`
+		_, err = context.EvalWithOptions(code, EvalOptions{Filename: "main.js"})
+		assert.NoError(t, err)
+		value, _ := context.Eval("result")
+		assert.Equal(t, 20, value.ToNative())
+
+		_, err = context.Eval(`import "./missing.js"`)
+		assert.Error(t, err)
+
+		runtime.SetModuleLoader(nil)
+		_, err = context.Eval(`import "./other.js"`)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewModule(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		add := func(call Call) (Value, error) {
+			sum := call.Arg(0).ToPrimitive().(int) + call.Arg(1).ToPrimitive().(int)
+			return call.ToValue(sum), nil
+		}
+		exports := map[string]any{"add": add, "names": []any{"a", "b"}}
+		assert.NoError(t, context.NewModule("go:util", exports))
+		code := `
+			import { add, names } from "go:util"
+			globalThis.result = [add(1, 2), ...names]`
+		_, err := context.Eval(code)
+		assert.NoError(t, err)
+		value, _ := context.Eval("result")
+		assert.Equal(t, []any{3, "a", "b"}, value.ToNative())
+
+		value, _ = context.Eval("typeof add")
+		assert.Equal(t, "undefined", value.ToNative())
+
+		_, err = context.Eval(`import { missing } from "go:util"`)
+		assert.Error(t, err)
+	})
+}
+
+func TestEvalModule(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		code := "export const a = 1; export function add(x) { return x + a }"
+		namespace, err := context.EvalModule(code, EvalOptions{Filename: "add.js"})
+		assert.NoError(t, err)
+		value, err := namespace.GetProperty("a")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, value.ToNative())
+		add, _ := namespace.GetProperty("add")
+		value, err = add.Object().Call(context.ToValue(nil), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, value.ToNative())
+
+		_, err = context.EvalModule("throw new RangeError('oops')")
+		assert.Error(t, err)
+		_, err = context.Eval("export {}; throw new RangeError('oops')")
+		assert.Error(t, err)
+
+		options := CompileOptions{EvalOptions: EvalOptions{Type: EvalModule}}
+		byteCode, err := context.CompileWithOptions("export default 'compiled'", options)
+		assert.NoError(t, err)
+		namespace, err = context.EvalModuleBinary(byteCode)
+		assert.NoError(t, err)
+		value, _ = namespace.GetProperty("default")
+		assert.Equal(t, "compiled", value.ToNative())
+
+		byteCode, _ = context.Compile("1 + 1")
+		_, err = context.EvalModuleBinary(byteCode)
+		assert.Error(t, err)
+	})
+}
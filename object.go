@@ -9,14 +9,14 @@ import (
 
 type ObjectKind uint8
 
-var builtinKinds = [17]string{
+var builtinKinds = [18]string{
 	"Object", "Boolean",
 	"Number", "BigInt", "Date", "String",
 	"Int8Array", "Int16Array", "Int32Array",
 	"Uint8Array", "Uint16Array", "Uint32Array",
 	"Float32Array", "Float64Array",
 	"Map", "Set",
-	"ArrayBuffer",
+	"ArrayBuffer", "Promise",
 }
 
 const (
@@ -38,6 +38,7 @@ const (
 	KindMap
 	KindSet
 	KindArrayBuffer
+	KindPromise
 	KindUnknown
 	KindMax = KindUnknown
 )
@@ -48,8 +49,9 @@ func (o Object) Kind() ObjectKind {
 	if C.JS_IsArray(o.context.raw, o.raw) == 1 {
 		return KindArray
 	}
-	property, _ := o.GetProperty("constructor")
-	if kind, ok := o.context.objectKinds[property.raw]; ok {
+	property := o.getProperty("constructor")
+	defer C.JS_FreeValue(o.context.raw, property)
+	if kind, ok := o.context.objectKinds[property]; ok {
 		return kind
 	}
 	return KindUnknown
@@ -70,40 +72,95 @@ func (o Object) HasProperty(name string) bool {
 	return retval
 }
 
+func (o Object) DeleteProperty(name string) bool {
+	atom := C.JS_NewAtom(o.context.raw, strPtr(name+"\x00"))
+	retval := C.JS_DeleteProperty(o.context.raw, o.raw, atom, 0) == 1
+	C.JS_FreeAtom(o.context.raw, atom)
+	return retval
+}
+
 func (o Object) GetOwnPropertyNames() []string {
+	return o.ownPropertyNames(flagStringMask | flagSymbolMask | flagPrivateMask)
+}
+
+func (o Object) ownPropertyNames(flags C.int) []string {
+	var properties []string
+	o.ownProperties(flags, func(name C.JSAtom) {
+		properties = append(properties, atom{o.context, name}.String())
+	})
+	return properties
+}
+
+// Call fn with atom of each own property, atom is only valid during the call
+func (o Object) ownProperties(flags C.int, fn func(C.JSAtom)) {
 	var enumPtr *C.JSPropertyEnum
 	var size C.uint32_t
-	flags := C.int(flagStringMask | flagSymbolMask | flagPrivateMask)
-	result := int(C.JS_GetOwnPropertyNames(o.context.raw, &enumPtr, &size, o.raw, flags))
-	if result < 0 {
-		return nil
-	}
-	enums := unsafe.Slice(enumPtr, size)
-	properties := make([]string, size)
-	for i := 0; i < int(size); i++ {
-		enum := enums[i]
-		properties[i] = atom{o.context, enum.atom}.String()
+	if C.JS_GetOwnPropertyNames(o.context.raw, &enumPtr, &size, o.raw, flags) < 0 {
+		return
+	}
+	for _, enum := range unsafe.Slice(enumPtr, size) {
+		fn(enum.atom)
 		C.JS_FreeAtom(o.context.raw, enum.atom)
 	}
 	C.js_free(o.context.raw, unsafe.Pointer(enumPtr))
-	return properties
+}
+
+// Convert value owned by caller to native and release it
+func (c *Context) consumeNative(value C.JSValue) any {
+	defer C.JS_FreeValue(c.raw, value)
+	return Value{c, value}.ToNative()
+}
+
+// Value of own data property, or false if property is missing or an accessor.
+// Value is owned by caller and no getter is called
+func (o Object) ownDataProperty(atom C.JSAtom) (C.JSValue, bool) {
+	var desc C.JSPropertyDescriptor
+	switch C.JS_GetOwnProperty(o.context.raw, &desc, o.raw, atom) {
+	case 0:
+		return null, false
+	case -1:
+		C.JS_FreeValue(o.context.raw, C.JS_GetException(o.context.raw))
+		return null, false
+	}
+	C.JS_FreeValue(o.context.raw, desc.getter)
+	C.JS_FreeValue(o.context.raw, desc.setter)
+	if desc.flags&C.JS_PROP_GETSET != 0 {
+		C.JS_FreeValue(o.context.raw, desc.value)
+		return null, false
+	}
+	return desc.value, true
+}
+
+// Same as ownDataProperty but looks up prototype chain as well
+func (o Object) dataProperty(atom C.JSAtom) (C.JSValue, bool) {
+	object := C.JS_DupValue(o.context.raw, o.raw)
+	defer func() { C.JS_FreeValue(o.context.raw, object) }()
+	for C.JS_IsObject(object) == 1 {
+		if value, ok := (Object{Value{o.context, object}}).ownDataProperty(atom); ok {
+			return value, true
+		}
+		proto := C.JS_GetPrototype(o.context.raw, object)
+		if C.JS_IsException(proto) == 1 { // Thrown by proxy
+			C.JS_FreeValue(o.context.raw, C.JS_GetException(o.context.raw))
+		}
+		C.JS_FreeValue(o.context.raw, object)
+		object = proto
+	}
+	return null, false
 }
 
 func (o Object) plainObjectToNative() any {
-	jsValue, _ := o.GetProperty("length")
-	if length, ok := jsValue.ToPrimitive().(int); ok {
+	if length, ok := o.context.consumeNative(o.getProperty("length")).(int); ok {
 		retval := make([]any, length)
 		for i := 0; i < length; i++ {
-			jsValue := Value{o.context, o.getPropertyByIndex(uint32(i))}
-			retval[i] = jsValue.ToNative()
+			retval[i] = o.context.consumeNative(o.getPropertyByIndex(uint32(i)))
 		}
 		return retval
 	}
 	names := o.GetOwnPropertyNames()
 	retval := make(map[string]any, len(names))
 	for _, name := range names {
-		property, _ := o.GetProperty(name)
-		retval[name] = property.ToNative()
+		retval[name] = o.context.consumeNative(o.getProperty(name))
 	}
 	return retval
 }
@@ -170,15 +227,20 @@ func (o Object) IsFunction() bool {
 	return C.JS_IsFunction(o.context.raw, o.raw) == 1
 }
 
+// Returned value is owned by caller, release it by Free
 func (o Object) Call(this Value, args ...any) (Value, error) {
-	jsArgs := make([]C.JSValue, len(args))
+	jsArgs := make([]C.JSValue, len(args)+1) // Never empty
 	for i, arg := range args {
 		jsArgs[i] = o.context.toValue(arg)
 	}
 	retval := o.call(this.raw, len(args), &jsArgs[0])
+	for _, arg := range jsArgs[:len(args)] {
+		C.JS_FreeValue(o.context.raw, arg)
+	}
 	if err := o.context.checkException(retval); err != nil {
 		return o.context.ToValue(Undefined), err
 	}
+	o.context.handles[retval]++
 	return Value{o.context, retval}, nil
 }
 
@@ -186,6 +248,9 @@ func (o Object) getProperty(name string) C.JSValue {
 	return C.JS_GetPropertyStr(o.context.raw, o.raw, strPtr(name+"\x00"))
 }
 
+// Returned value is borrowed from the object, retain it by Dup. Value
+// returned by getter or proxy is not held by the object and is released
+// before return, use DupProperty unless property is known to hold the value
 func (o Object) GetProperty(name string) (Value, error) {
 	jsValue := o.getProperty(name)
 	if err := o.context.checkException(jsValue); err != nil {
@@ -195,6 +260,19 @@ func (o Object) GetProperty(name string) (Value, error) {
 	return Value{o.context, jsValue}, nil
 }
 
+// Same as GetProperty but returned value is owned by caller, release it by Free
+func (o Object) DupProperty(name string) (Value, error) {
+	return o.context.owned(o.getProperty(name))
+}
+
+func (c *Context) owned(value C.JSValue) (Value, error) {
+	if err := c.checkException(value); err != nil {
+		return Value{}, err
+	}
+	c.handles[value]++
+	return Value{c, value}, nil
+}
+
 func (o Object) setProperty(name string, value C.JSValue) {
 	C.JS_SetPropertyStr(o.context.raw, o.raw, strPtr(name+"\x00"), value)
 }
@@ -205,19 +283,27 @@ func (o Object) SetProperty(name string, value any) {
 }
 
 func (o Object) SetValue(name string, value Value) {
-	o.setProperty(name, value.raw)
+	o.setProperty(name, o.context.consume(value))
 }
 
 func (o Object) getPropertyByIndex(index uint32) C.JSValue {
 	return C.JS_GetPropertyUint32(o.context.raw, o.raw, C.uint32_t(index))
 }
 
+// Returned value is borrowed from the object, retain it by Dup. Same as
+// GetProperty, use DupPropertyByIndex for value returned by getter or proxy
 func (o Object) GetPropertyByIndex(index uint32) Value {
 	jsValue := o.getPropertyByIndex(index)
 	C.JS_FreeValue(o.context.raw, jsValue)
 	return Value{o.context, jsValue}
 }
 
+// Same as GetPropertyByIndex but returned value is owned by caller,
+// release it by Free
+func (o Object) DupPropertyByIndex(index uint32) (Value, error) {
+	return o.context.owned(o.getPropertyByIndex(index))
+}
+
 func (o Object) setPropertyByIndex(index uint32, value C.JSValue) {
 	C.JS_SetPropertyUint32(o.context.raw, o.raw, C.uint32_t(index), value)
 }
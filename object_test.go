@@ -91,6 +91,38 @@ func TestSetProperty(t *testing.T) {
 	})
 }
 
+func TestDupProperty(t *testing.T) {
+	runtime := NewRuntime(Config{Debug: true, LeakHandler: FailOnLeak(t)})
+	runtime.NewContext().With(func(context *Context) {
+		getters, err := context.Eval(`({
+			get fresh() { return { name: "fresh" } },
+			get broken() { throw new Error("broken") },
+		})`)
+		assert.NoError(t, err)
+		fresh, err := getters.Object().DupProperty("fresh")
+		assert.NoError(t, err)
+		name, _ := fresh.Object().GetProperty("name")
+		assert.Equal(t, "fresh", name.String())
+		fresh.Free()
+		_, err = getters.Object().DupProperty("broken")
+		assert.Equal(t, "Error: broken", err.Error())
+
+		object, err := context.Eval(`({ get fresh() { return { name: "fresh" } } })`)
+		assert.NoError(t, err)
+		expected := map[string]any{"fresh": map[string]any{"name": "fresh"}}
+		assert.Equal(t, expected, object.ToNative())
+
+		array, err := context.Eval(`
+			new Proxy([], { get: (_, key) => key == "length" ? 1 : { index: key } })`)
+		assert.NoError(t, err)
+		element, err := array.Object().DupPropertyByIndex(0)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"index": "0"}, element.ToNative())
+		element.Free()
+		assert.Equal(t, []any{map[string]any{"index": "0"}}, array.ToNative())
+	})
+}
+
 func TestFinalizer(t *testing.T) {
 	NewRuntime().NewContext().With(func(context *Context) {
 		proto, classID := context.goObjectProto, context.runtime.goObject
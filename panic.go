@@ -0,0 +1,63 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// Panic recovered from Go callback, thrown to javascript as InternalError
+// and returned as Error.Err if not caught
+type PanicError struct {
+	Value any    // Value passed to panic
+	Stack []byte // Go stack where panic occurred
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Value passed to panic if it is an error
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Deferred by callbacks, recovered panic is thrown through retval. With
+// Config.Repanic thrown error is uncatchable, then PanicError is panicked
+// again once javascript is unwound and exception reaches Go
+func (c *Context) recoverPanic(retval *C.JSValue) {
+	if value := recover(); value != nil {
+		*retval = c.throwPanic(c.raw, value)
+	}
+}
+
+// Deferred by module loader callbacks, which return failed once recovered
+// panic is thrown to ctx
+func recoverModulePanic[T any](ctx *C.JSContext, retval *T, failed T) {
+	if value := recover(); value != nil {
+		getContext(ctx).throwPanic(ctx, value)
+		*retval = failed
+	}
+}
+
+// Throw recovered panic to raw, which is either context itself or its compiler
+func (c *Context) throwPanic(raw *C.JSContext, value any) C.JSValue {
+	err := &PanicError{Value: value, Stack: debug.Stack()}
+	c.throw(err)
+	exception := C.JS_GetException(c.raw)
+	if c.runtime.repanic {
+		C.JS_SetUncatchableError(c.raw, exception, 1)
+		c.runtime.panicked = err
+	}
+	return C.JS_Throw(raw, exception) // Contexts share the runtime
+}
+
+// Finalizer and rejection tracker are unable to throw, so recovered panic is logged
+func recoverLoggedPanic(callback string) {
+	if value := recover(); value != nil {
+		log.Printf("quickjs: panic in %s: %v\n%s", callback, value, debug.Stack())
+	}
+}
@@ -0,0 +1,100 @@
+package quickjs
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	NewRuntime().NewContext().With(func(context *Context) {
+		context.GlobalObject().SetFunc("crash", func(call Call) (Value, error) {
+			if call.NumArgs() > 0 {
+				panic(io.EOF)
+			}
+			return call.ToValue(call.Arg(0).ToPrimitive().(int)), nil // Index out of range
+		})
+		value, err := context.Eval(`
+			try { crash() } catch (e) { [e.name, e.message.startsWith("panic:")] }`)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{"InternalError", true}, value.ToNative())
+
+		_, err = context.Eval(`crash(1)`)
+		var panicError *PanicError
+		assert.ErrorAs(t, err, &panicError)
+		assert.Equal(t, io.EOF, panicError.Value)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Contains(t, string(panicError.Stack), "panic_test.go")
+
+		object := context.ToObject(&bigInt{*big.NewInt(1)})
+		context.GlobalObject().SetValue("ut", object)
+		_, err = context.Eval(`ut.add("1")`) // Failed type assertion
+		assert.ErrorAs(t, err, &panicError)
+	})
+}
+
+func TestRepanic(t *testing.T) {
+	NewRuntime(Config{Repanic: true}).NewContext().With(func(context *Context) {
+		context.GlobalObject().SetFunc("crash", func(call Call) (Value, error) {
+			panic(io.EOF)
+		})
+		context.GlobalObject().SetFunc("object", func(call Call) (Value, error) {
+			return call.Context.ToObject(&jsonMarshaller{1, "2"}), nil
+		})
+		value, err := context.Eval(`object()`)
+		assert.NoError(t, err)
+		assert.Equal(t, &jsonMarshaller{1, "2"}, value.ToNative())
+
+		var recovered any
+		func() {
+			defer func() { recovered = recover() }()
+			_, _ = context.Eval(`try { crash() } catch (e) { globalThis.caught = true }`)
+		}()
+		panicError, ok := recovered.(*PanicError)
+		assert.True(t, ok)
+		assert.True(t, errors.Is(panicError, io.EOF))
+		assert.Equal(t, null, context.evalRet) // Freed before evaluation
+
+		value, err = context.Eval(`typeof caught`)
+		assert.NoError(t, err)
+		assert.Equal(t, "undefined", value.ToNative())
+	})
+}
+
+type panicLoader struct{ resolve bool }
+
+func (p panicLoader) Resolve(base, specifier string) (string, error) {
+	if p.resolve {
+		panic(io.EOF)
+	}
+	return specifier, nil
+}
+
+func (p panicLoader) Load(name string) (ModuleCode, error) {
+	panic(io.EOF)
+}
+
+func TestModuleLoaderPanic(t *testing.T) {
+	compiled := ContextOptions{Intrinsics: IntrinsicStandard &^ IntrinsicEval}
+	for _, options := range []ContextOptions{{Intrinsics: IntrinsicStandard}, compiled} {
+		for _, resolve := range []bool{true, false} {
+			runtime := NewRuntime()
+			runtime.SetModuleLoader(panicLoader{resolve})
+			runtime.NewContextWithOptions(options).With(func(context *Context) {
+				_, err := context.EvalModule(`import "crash"`)
+				var panicError *PanicError
+				assert.ErrorAs(t, err, &panicError)
+				assert.ErrorIs(t, err, io.EOF)
+			})
+		}
+	}
+
+	runtime := NewRuntime(Config{Repanic: true})
+	runtime.SetModuleLoader(panicLoader{})
+	runtime.NewContext().With(func(context *Context) {
+		assert.PanicsWithError(t, "panic: EOF", func() { context.EvalModule(`import "crash"`) })
+	})
+}
@@ -0,0 +1,216 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import "sync"
+
+type PoolConfig struct {
+	Size    int    // Number of ready contexts kept by pool
+	Runtime Config // Config of each runtime, ManualFree is always enabled
+	// Install globals or pre-evaluate bytecode for new context
+	Init func(*Context) error
+	// Optional, called after built-in reset when context returned to pool,
+	// context discarded on error
+	Reset func(*Context) error
+	// Discard returned context if its runtime uses more memory after GC, 0 means unlimited
+	MaxMemoryUsage int64
+}
+
+// Pool keeps pre-warmed contexts each owning a dedicated runtime.
+//
+// Context returned to pool is reset before reuse: global properties added
+// after Init are deleted, pending timers are cleared, values retained by
+// Value.Dup are released, and context with pending jobs, AsyncFunc calls or
+// unreported rejections is discarded. Context is discarded as well if an
+// added global can not be deleted, e.g. declared by var, or if top level let,
+// const or class is declared after Init. Objects mutated in place survive
+// reset, so scripts run by pooled contexts should be wrapped in a function
+// or evaluated as module to stay reusable.
+type Pool struct {
+	config  PoolConfig
+	mutex   sync.Mutex
+	closed  bool
+	idle    chan ContextGuard
+	globals map[*Context]globalSnapshot
+}
+
+// Global state of context right after Init
+type globalSnapshot struct {
+	names     map[string]struct{}
+	lexicals  int // Never removed once declared
+	eventLoop bool
+}
+
+func NewPool(config PoolConfig) (*Pool, error) {
+	config.Runtime.ManualFree = true
+	pool := &Pool{config: config, idle: make(chan ContextGuard, config.Size)}
+	pool.globals = make(map[*Context]globalSnapshot)
+	for i := 0; i < config.Size; i++ {
+		guard, err := pool.newContext()
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.idle <- guard
+	}
+	return pool, nil
+}
+
+func (p *Pool) newContext() (ContextGuard, error) {
+	runtime := NewRuntime(p.config.Runtime)
+	guard := runtime.NewContext()
+	runtime.Free()
+	var err error
+	var snapshot globalSnapshot
+	guard.With(func(context *Context) {
+		if p.config.Init != nil {
+			err = p.config.Init(context)
+		}
+		snapshot.names = make(map[string]struct{})
+		for _, name := range context.GlobalObject().ownPropertyNames(flagStringMask) {
+			snapshot.names[name] = struct{}{}
+		}
+		snapshot.lexicals = len(context.lexicalNames())
+		snapshot.eventLoop = context.eventLoop != nil
+	})
+	if err != nil {
+		guard.Free()
+		return ContextGuard{}, err
+	}
+	p.mutex.Lock()
+	p.globals[guard.context] = snapshot
+	p.mutex.Unlock()
+	return guard, nil
+}
+
+// Take a ready context from pool, or create a new one when pool is drained.
+// Returns ErrPoolClosed once pool is closed
+func (p *Pool) Get() (ContextGuard, error) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return ContextGuard{}, ErrPoolClosed
+	}
+	select {
+	case guard := <-p.idle:
+		p.mutex.Unlock()
+		return guard, nil
+	default:
+		p.mutex.Unlock()
+		return p.newContext()
+	}
+}
+
+// Names of top level let, const and class declarations, which are not
+// properties of global object
+func (c *Context) lexicalNames() []string {
+	object := C.JS_GetGlobalVarObject(c.raw)
+	defer C.JS_FreeValue(c.raw, object)
+	return Value{c, object}.Object().ownPropertyNames(flagStringMask)
+}
+
+// Restore global state captured after Init, return false if impossible
+func (p *Pool) reset(context *Context, snapshot globalSnapshot) bool {
+	context.releaseHandles()
+	if len(context.lexicalNames()) != snapshot.lexicals {
+		return false
+	}
+	runtime := context.runtime
+	if C.JS_IsJobPending(runtime.raw) != 0 || len(runtime.asyncCalls) > 0 {
+		return false
+	}
+	if len(runtime.rejections) > 0 || len(runtime.posted.take()) > 0 {
+		return false
+	}
+	if context.eventLoop != nil {
+		context.eventLoop.release()
+		if !snapshot.eventLoop {
+			context.eventLoop = nil // Timer globals are deleted below
+		}
+	}
+	C.JS_FreeValue(context.raw, context.evalRet)
+	context.evalRet = null
+	global := context.GlobalObject()
+	for _, name := range global.ownPropertyNames(flagStringMask) {
+		if _, ok := snapshot.names[name]; !ok && !global.DeleteProperty(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Pool) reusable(guard ContextGuard) bool {
+	p.mutex.Lock()
+	snapshot := p.globals[guard.context]
+	p.mutex.Unlock()
+	var err error
+	ok := true
+	guard.With(func(context *Context) {
+		if ok = p.reset(context, snapshot); !ok {
+			return
+		}
+		if p.config.Reset != nil {
+			err = p.config.Reset(context)
+		}
+		context.runtime.RunGC()
+	})
+	if !ok || err != nil {
+		return false
+	}
+	usage := guard.context.runtime.GetMemoryUsage()
+	return p.config.MaxMemoryUsage <= 0 || usage.MemoryUsedSize <= p.config.MaxMemoryUsage
+}
+
+func (p *Pool) discard(guard ContextGuard) {
+	p.mutex.Lock()
+	delete(p.globals, guard.context)
+	p.mutex.Unlock()
+	guard.Free()
+}
+
+// Return context to pool, context is discarded and replaced with
+// a new one if failed to reset or uses too much memory.
+// Context put back after Close is freed
+func (p *Pool) Put(guard ContextGuard) {
+	p.mutex.Lock()
+	closed := p.closed
+	p.mutex.Unlock()
+	if closed {
+		p.discard(guard)
+		return
+	}
+	if !p.reusable(guard) {
+		p.discard(guard)
+		var err error
+		if guard, err = p.newContext(); err != nil {
+			return
+		}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.closed {
+		select {
+		case p.idle <- guard:
+			return
+		default:
+		}
+	}
+	delete(p.globals, guard.context)
+	guard.Free()
+}
+
+// Free all idle contexts, contexts taken out are freed when put back
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	for {
+		select {
+		case guard := <-p.idle:
+			delete(p.globals, guard.context)
+			guard.Free()
+		default:
+			return
+		}
+	}
+}
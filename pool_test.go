@@ -0,0 +1,182 @@
+package quickjs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	config := PoolConfig{Size: 2, Runtime: DefaultConfig()}
+	config.Init = func(context *Context) error {
+		_, err := context.Eval("globalThis.greeting = 'hello'")
+		return err
+	}
+	config.Reset = func(context *Context) error {
+		_, err := context.Eval("delete globalThis.scratch")
+		return err
+	}
+	pool, err := NewPool(config)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	first, err := pool.Get()
+	assert.NoError(t, err)
+	second, err := pool.Get()
+	assert.NoError(t, err)
+	third, err := pool.Get()
+	assert.NoError(t, err)
+	first.With(func(context *Context) {
+		value, err := context.Eval("globalThis.scratch = greeting")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", value.ToNative())
+	})
+	pool.Put(first)
+	pool.Put(second)
+	pool.Put(third)
+
+	guard, err := pool.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, first, guard)
+	guard.With(func(context *Context) {
+		value, err := context.Eval("typeof scratch")
+		assert.NoError(t, err)
+		assert.Equal(t, "undefined", value.ToNative())
+	})
+	pool.Put(guard)
+}
+
+func TestPoolDiscard(t *testing.T) {
+	config := PoolConfig{Size: 1, Runtime: DefaultConfig(), MaxMemoryUsage: 4 << 20}
+	config.Init = func(context *Context) error {
+		_, err := context.Eval("globalThis.store = []")
+		return err
+	}
+	pool, err := NewPool(config)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	guard, _ := pool.Get()
+	guard.With(func(context *Context) {
+		_, err := context.Eval("store.push(new Array(1 << 20).fill(0))")
+		assert.NoError(t, err)
+	})
+	pool.Put(guard)
+	fresh, _ := pool.Get()
+	assert.NotEqual(t, guard, fresh)
+	fresh.With(func(context *Context) {
+		value, _ := context.Eval("store.length")
+		assert.Equal(t, 0, value.ToNative())
+	})
+	pool.Put(fresh)
+
+	config.Init = func(*Context) error { return errors.New("init") }
+	_, err = NewPool(config)
+	assert.Error(t, err)
+}
+
+func TestPoolReset(t *testing.T) {
+	config := PoolConfig{Size: 1, Runtime: DefaultConfig()}
+	config.Init = func(context *Context) error {
+		context.NewEventLoop()
+		_, err := context.Eval("globalThis.greeting = 'hello'")
+		return err
+	}
+	pool, err := NewPool(config)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	guard, _ := pool.Get()
+	guard.With(func(context *Context) {
+		_, err := context.Eval("globalThis.scratch = 1; setTimeout(() => {}, 1000)")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, context.eventLoop.Len())
+	})
+	pool.Put(guard)
+	reused, _ := pool.Get()
+	assert.Equal(t, guard, reused)
+	reused.With(func(context *Context) {
+		value, err := context.Eval("[typeof scratch, greeting].join()")
+		assert.NoError(t, err)
+		assert.Equal(t, "undefined,hello", value.ToNative())
+		assert.Equal(t, 0, context.eventLoop.Len())
+		_, err = context.Eval("Promise.resolve().then(() => {})")
+		assert.NoError(t, err)
+	})
+	pool.Put(reused)
+	fresh, _ := pool.Get()
+	assert.NotEqual(t, reused, fresh)
+
+	fresh.With(func(context *Context) {
+		_, err := context.Eval("var declared = 1")
+		assert.NoError(t, err)
+	})
+	pool.Put(fresh)
+	guard, _ = pool.Get()
+	assert.NotEqual(t, fresh, guard)
+	pool.Put(guard)
+}
+
+func TestPoolLexical(t *testing.T) {
+	config := PoolConfig{Size: 1, Runtime: DefaultConfig()}
+	config.Init = func(context *Context) error {
+		_, err := context.Eval("const shared = 1")
+		return err
+	}
+	pool, err := NewPool(config)
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		guard, _ := pool.Get()
+		guard.With(func(context *Context) {
+			value, err := context.Eval("typeof secret")
+			assert.NoError(t, err)
+			assert.Equal(t, "undefined", value.ToNative())
+			value, err = context.Eval("const secret = shared + 41; secret")
+			assert.NoError(t, err)
+			assert.Equal(t, 42, value.ToNative())
+		})
+		pool.Put(guard)
+	}
+
+	guard, _ := pool.Get()
+	guard.With(func(context *Context) {
+		value, err := context.Eval("({})")
+		assert.NoError(t, err)
+		value.Dup()
+		assert.Len(t, context.handles, 1)
+	})
+	pool.Put(guard)
+	reused, _ := pool.Get()
+	assert.Equal(t, guard, reused)
+	assert.Empty(t, reused.context.handles)
+	pool.Put(reused)
+}
+
+func TestPoolClose(t *testing.T) {
+	pool, err := NewPool(PoolConfig{Size: 2, Runtime: DefaultConfig()})
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 8; j++ {
+				guard, err := pool.Get()
+				if err != nil {
+					assert.ErrorIs(t, err, ErrPoolClosed)
+					return
+				}
+				pool.Put(guard)
+			}
+		}()
+	}
+	pool.Close()
+	wg.Wait()
+	_, err = pool.Get()
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.Empty(t, pool.idle)
+}
@@ -0,0 +1,203 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Returned by Await when promise is still pending but neither job is queued
+// nor timer or AsyncFunc result is awaited, i.e. it waits for something never happen
+var ErrPromisePending = errors.New("promise pending")
+
+type PromiseState uint8
+
+const (
+	PromisePending PromiseState = iota
+	PromiseFulfilled
+	PromiseRejected
+)
+
+func (s PromiseState) String() string {
+	switch s {
+	case PromisePending:
+		return "pending"
+	case PromiseFulfilled:
+		return "fulfilled"
+	default:
+		return "rejected"
+	}
+}
+
+type Promise struct{ Object }
+
+// Assume object is Promise
+func (o Object) Promise() Promise { return Promise{o} }
+
+func (v Value) IsPromise() bool {
+	return C.int(C.JS_PromiseState(v.context.raw, v.raw)) >= 0
+}
+
+func (p Promise) State() PromiseState {
+	switch C.JS_PromiseState(p.context.raw, p.raw) {
+	case C.JS_PROMISE_FULFILLED:
+		return PromiseFulfilled
+	case C.JS_PROMISE_REJECTED:
+		return PromiseRejected
+	default:
+		return PromisePending
+	}
+}
+
+// Fulfilled value or rejection reason, undefined if pending.
+// Returned value is borrowed from the promise, retain it by Dup
+func (p Promise) Result() Value {
+	jsValue := C.JS_PromiseResult(p.context.raw, p.raw)
+	C.JS_FreeValue(p.context.raw, jsValue)
+	return Value{p.context, jsValue}
+}
+
+// Execute one pending job of the runtime, return false if there is none
+func (c *Context) runPendingJob() (bool, error) {
+	var jobContext *C.JSContext
+	switch C.JS_ExecutePendingJob(c.runtime.raw, &jobContext) {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return true, getContext(jobContext).getException()
+	}
+}
+
+// Promise rejected without handler, reported unless handler is attached
+// before pending jobs are drained
+type rejection struct {
+	context         *Context
+	promise, reason C.JSValue
+}
+
+func (r rejection) free() {
+	C.JS_FreeValue(r.context.raw, r.promise)
+	C.JS_FreeValue(r.context.raw, r.reason)
+}
+
+// Returned by EventLoop runs for each promise rejected without handler
+type UnhandledRejectionError struct{ Reason error }
+
+func (e *UnhandledRejectionError) Error() string {
+	return "unhandled rejection: " + e.Reason.Error()
+}
+
+func (e *UnhandledRejectionError) Unwrap() error { return e.Reason }
+
+// Handler is called for each promise rejected without handler once pending
+// jobs are drained, or when its context is freed before that. Rejections
+// returned as error by Await or EvalModule count as handled. Promise and
+// reason are only valid during the call
+func (r *Runtime) OnUnhandledRejection(handler func(context *Context, promise, reason Value)) {
+	r.onRejection = handler
+}
+
+func (r *Runtime) reportRejections() {
+	rejections := r.rejections
+	r.rejections = nil
+	for _, rejection := range rejections {
+		c := rejection.context
+		if r.onRejection != nil {
+			r.onRejection(c, Value{c, rejection.promise}, Value{c, rejection.reason})
+		}
+		if loop := c.eventLoop; loop != nil {
+			reason := c.toError(Value{c, C.JS_DupValue(c.raw, rejection.reason)})
+			loop.unhandled = append(loop.unhandled, &UnhandledRejectionError{reason})
+		}
+		rejection.free()
+	}
+}
+
+// Execute one pending job, posted jobs or due timer of event loop,
+// return false if nothing is ready
+func (c *Context) runJob() (bool, error) {
+	executed, err := c.runPendingJob()
+	if executed || err != nil {
+		return executed, err
+	}
+	c.runtime.reportRejections()
+	if c.runtime.runPostedJobs() {
+		return true, nil
+	}
+	if c.eventLoop != nil {
+		return c.eventLoop.runTimer()
+	}
+	return false, nil
+}
+
+// Block until posted job arrives, next timer is due or ctx is done,
+// return false if neither timer nor AsyncFunc result is expected
+func (c *Context) wait(ctx context.Context) bool {
+	var timeout <-chan time.Time
+	if c.eventLoop != nil {
+		timeout = c.eventLoop.nextTimer()
+	}
+	if timeout == nil && len(c.runtime.asyncCalls) == 0 {
+		return false
+	}
+	select {
+	case <-timeout:
+	case <-c.runtime.posted.notify:
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// Execute pending jobs such as promise reactions and settling by AsyncFunc
+// results already delivered until job queue is empty, without waiting for
+// AsyncFunc results still in progress. Jobs queued by other contexts of the
+// same runtime are executed as well. Draining stops at the first job throwing
+func (c *Context) RunPendingJobs() error {
+	for {
+		executed, err := c.runPendingJob()
+		if err != nil {
+			return err
+		}
+		if executed {
+			continue
+		}
+		c.runtime.reportRejections()
+		if !c.runtime.runPostedJobs() {
+			return nil
+		}
+	}
+}
+
+// Execute pending jobs and wait for AsyncFunc results as well as timers of
+// event loop until promise settles,
+// return fulfilled value or rejection reason as error.
+// Value not being a promise is returned as is.
+// Returned value is borrowed from the promise, retain it by Dup
+func (v Value) Await(ctx context.Context) (Value, error) {
+	if !v.IsPromise() {
+		return v, nil
+	}
+	c, promise := v.context, v.Object().Promise()
+	return c.withContext(ctx, func() (Value, error) {
+		for promise.State() == PromisePending {
+			if err := (cancellation{ctx}).interrupt(); err != nil {
+				return c.ToValue(Undefined), err
+			}
+			executed, err := c.runJob()
+			if err != nil {
+				return c.ToValue(Undefined), err
+			}
+			if !executed && !c.wait(ctx) {
+				return c.ToValue(Undefined), ErrPromisePending
+			}
+		}
+		if err := c.checkPromise(promise.raw); err != nil {
+			return c.ToValue(Undefined), err
+		}
+		return promise.Result(), nil
+	})
+}
@@ -0,0 +1,105 @@
+package quickjs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromise(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	NewRuntime().NewContext().With(func(context *Context) {
+		value, err := context.Eval(`(async () => { await null; return 1 + 1 })()`)
+		assert.NoError(t, err)
+		assert.True(t, value.IsPromise())
+		assert.Equal(t, KindPromise, value.Object().Kind())
+		assert.Equal(t, PromisePending, value.Object().Promise().State())
+		result, err := value.Await(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.ToNative())
+		assert.Equal(t, PromiseFulfilled, value.Object().Promise().State())
+
+		value, _ = context.Eval(`(async () => { await null; throw new Error("failed") })()`)
+		_, err = value.Await(ctx)
+		assert.ErrorContains(t, err, "failed")
+		assert.Equal(t, PromiseRejected, value.Object().Promise().State())
+
+		value, _ = context.Eval(`new Promise(() => {})`)
+		_, err = value.Await(ctx)
+		assert.ErrorIs(t, err, ErrPromisePending)
+
+		value, _ = context.Eval(`1`)
+		result, err = value.Await(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.ToNative())
+
+		_, _ = context.Eval(`var done = false; Promise.resolve().then(() => { done = true })`)
+		done, _ := context.GlobalObject().GetProperty("done")
+		assert.Equal(t, false, done.ToNative())
+		assert.NoError(t, context.RunPendingJobs())
+		done, _ = context.GlobalObject().GetProperty("done")
+		assert.Equal(t, true, done.ToNative())
+	})
+}
+
+func TestAwaitContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	NewRuntime().NewContext().With(func(context *Context) {
+		value, _ := context.Eval(`(async () => { await null; while (true) {} })()`)
+		_, err := value.Await(ctx)
+		assert.ErrorIs(t, err, ErrInterrupted)
+		assert.ErrorIs(t, err, ctx.Err())
+	})
+}
+
+func TestUnhandledRejection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	runtime := NewRuntime(Config{ManualFree: true, Debug: true, LeakHandler: FailOnLeak(t)})
+	var reasons []any
+	runtime.OnUnhandledRejection(func(context *Context, promise, reason Value) {
+		assert.Equal(t, PromiseRejected, promise.Object().Promise().State())
+		reasons = append(reasons, reason.ToNative())
+	})
+	guard := runtime.NewContext()
+	guard.With(func(context *Context) {
+		_, err := context.Eval(`Promise.reject(1); Promise.reject(2).catch(() => {})`)
+		assert.NoError(t, err)
+		assert.NoError(t, context.RunPendingJobs())
+		assert.Equal(t, []any{1}, reasons)
+
+		clock := NewVirtualClock(time.Unix(0, 0))
+		loop := context.NewEventLoop(clock)
+		_, err = context.Eval(`setTimeout(async () => { throw new Error("async") }, 10)`)
+		assert.NoError(t, err)
+		clock.Advance(10 * time.Millisecond)
+		err = loop.Run(ctx)
+		var rejection *UnhandledRejectionError
+		assert.ErrorAs(t, err, &rejection)
+		assert.ErrorContains(t, err, "async")
+		assert.Len(t, reasons, 2)
+		assert.NoError(t, loop.Run(ctx))
+
+		// Rejections consumed by Await or EvalModule are not reported
+		value, err := context.Eval(`(async () => { await null; throw new Error("awaited") })()`)
+		assert.NoError(t, err)
+		_, err = value.Await(ctx)
+		assert.ErrorContains(t, err, "awaited")
+		_, err = context.EvalModule(`throw new Error("module")`)
+		assert.ErrorContains(t, err, "module")
+		assert.NoError(t, context.RunPendingJobs())
+		assert.NoError(t, loop.Run(ctx))
+		assert.Len(t, reasons, 2)
+
+		_, err = context.Eval(`Promise.reject(3)`)
+		assert.NoError(t, err)
+	})
+	guard.Free() // Rejection not yet reported is handed to handler
+	runtime.Free()
+	assert.Len(t, reasons, 3)
+	assert.Equal(t, 3, reasons[2])
+}
@@ -26,22 +26,32 @@ type MemoryUsage struct {
 	BinaryObjectCount, BinaryObjectSize     int64
 }
 
-type Error struct {
-	Cause, Stack string
-}
-
-func (e Error) Error() string {
-	return e.Cause
-}
-
 type Runtime struct {
 	raw        *C.JSRuntime
+	data       unsafe.Pointer // *runtimeData
 	manualFree bool
 	refCount   atomic.Int32
 
 	goObject, goFunc, goIndexCall C.JSClassID
+
+	interrupters []interrupter
+	interrupted  error
+	loader       ModuleLoader
+	posted       jobQueue
+	asyncCalls   map[*asyncCall]struct{} // Promises waiting for AsyncFunc results
+	rejections   []rejection             // Rejected without handler in current job
+	onRejection  func(*Context, Value, Value)
+	repanic      bool
+	panicked     *PanicError // Recovered panic to be panicked again
+
+	debug       bool
+	leakHandler func(LeakReport)
+	atomCount   int64 // Builtin atoms, which are never freed
 }
 
+// Passed as opaque to runtime level callbacks
+type runtimeData struct{ runtime *Runtime }
+
 func (r *Runtime) GetMemoryUsage() MemoryUsage {
 	var usage MemoryUsage
 	C.JS_ComputeMemoryUsage(r.raw, (*C.JSMemoryUsage)(unsafe.Pointer(&usage)))
@@ -55,24 +65,45 @@ func (r *Runtime) RunGC() {
 // Free runtime manually
 func (r *Runtime) Free() {
 	if r.refCount.Add(-1) == 0 {
+		if r.debug && !r.checkLeaks() {
+			return // Leaked instead of abort
+		}
 		C.JS_FreeRuntime(r.raw)
+		C.free(r.data)
 	}
 }
 
 func NewRuntime(config ...Config) *Runtime {
 	jsRuntime := &Runtime{raw: C.JS_NewRuntime()}
+	jsRuntime.posted.notify = make(chan struct{}, 1)
+	jsRuntime.asyncCalls = make(map[*asyncCall]struct{})
 	if len(config) > 0 {
 		config := config[0]
 		if size := config.MaxStackSize; size >= 0 {
 			C.JS_SetMaxStackSize(jsRuntime.raw, C.size_t(size))
 		}
+		if limit := config.MemoryLimit; limit > 0 {
+			C.JS_SetMemoryLimit(jsRuntime.raw, C.size_t(limit))
+		}
+		if threshold := config.GCThreshold; threshold > 0 {
+			C.JS_SetGCThreshold(jsRuntime.raw, C.size_t(threshold))
+		}
 		jsRuntime.manualFree = config.ManualFree
+		jsRuntime.debug, jsRuntime.leakHandler = config.Debug, config.LeakHandler
+		jsRuntime.repanic = config.Repanic
 	}
 	classIDs := [3]*C.JSClassID{&jsRuntime.goObject, &jsRuntime.goFunc, &jsRuntime.goIndexCall}
 	for i, classID := range classIDs {
 		C.JS_NewClassID(classID)
 		C.JS_NewClass(jsRuntime.raw, *classID, &C.go_classes[i])
 	}
+	jsRuntime.data = C.malloc(C.size_t(unsafe.Sizeof(runtimeData{})))
+	*(*runtimeData)(jsRuntime.data) = runtimeData{jsRuntime}
+	C.SetInterruptHandler(jsRuntime.raw, jsRuntime.data)
+	C.SetPromiseRejectionTracker(jsRuntime.raw, jsRuntime.data)
+	if jsRuntime.debug {
+		jsRuntime.atomCount = jsRuntime.GetMemoryUsage().AtomCount
+	}
 	jsRuntime.refCount.Add(1)
 	if !jsRuntime.manualFree {
 		runtime.SetFinalizer(jsRuntime, func(r *Runtime) { r.Free() })
@@ -0,0 +1,73 @@
+package quickjs
+
+//#include "ffi.h"
+import "C"
+
+// Scope tracks values created through it and releases them on exit,
+// other methods of Context are available as usual
+type Scope struct {
+	*Context
+	parent *Scope
+	values []Value
+}
+
+// Run fn with a new scope which is released when fn returns, scope created
+// inside fn is nested in it
+func (c *Context) Scope(fn func(*Scope)) {
+	scope := &Scope{Context: c, parent: c.scope}
+	c.scope = scope
+	defer func() {
+		c.scope = scope.parent
+		for _, value := range scope.values {
+			value.Free()
+		}
+	}()
+	fn(scope)
+}
+
+func (s *Scope) track(value Value) Value {
+	s.handles[value.raw]++
+	s.values = append(s.values, value)
+	return value
+}
+
+// Same as Context.ToValue but released on scope exit
+func (s *Scope) ToValue(value any) Value {
+	return s.track(Value{s.Context, s.toValue(value)})
+}
+
+// Same as Context.ToObject but released on scope exit
+func (s *Scope) ToObject(value any, options ...ObjectFlags) Value {
+	return s.track(s.Context.ToObject(value, options...))
+}
+
+// Retain value until scope exit, e.g. value returned by Eval or GetProperty
+func (s *Scope) Dup(value Value) Value {
+	return s.track(Value{s.Context, C.JS_DupValue(s.raw, value.raw)})
+}
+
+// Same as Object.Call but returned value is released on scope exit
+func (s *Scope) Call(fn Object, this Value, args ...any) (Value, error) {
+	retval, err := fn.Call(this, args...)
+	if err != nil {
+		return retval, err
+	}
+	s.values = append(s.values, retval)
+	return retval, nil
+}
+
+// Move value tracked by this scope to parent scope, or to caller if there is
+// no parent scope, in which case it must be released by Free
+func (s *Scope) Escape(value Value) Value {
+	for i := len(s.values) - 1; i >= 0; i-- {
+		if s.values[i].raw != value.raw {
+			continue
+		}
+		s.values = append(s.values[:i], s.values[i+1:]...)
+		if s.parent != nil {
+			s.parent.values = append(s.parent.values, value)
+		}
+		break
+	}
+	return value
+}
@@ -0,0 +1,43 @@
+package quickjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope(t *testing.T) {
+	runtime := NewRuntime(Config{ManualFree: true})
+	guard := runtime.NewContext()
+	guard.With(func(context *Context) {
+		value, _ := context.Eval(`(items, options) => items.length + options.offset`)
+		fn := value.Dup().Object()
+		runtime.RunGC()
+		baseline := runtime.GetMemoryUsage().ObjCount
+
+		var escaped Value
+		context.Scope(func(scope *Scope) {
+			for i := 0; i < 100; i++ {
+				items := scope.ToValue([]any{1, 2, 3})
+				retval, err := scope.Call(fn, scope.ToValue(nil), items, map[string]any{"offset": i})
+				assert.NoError(t, err)
+				assert.Equal(t, 3+i, retval.ToNative())
+			}
+			scope.Scope(func(inner *Scope) {
+				escaped = inner.Escape(inner.ToValue(map[string]any{"kept": true}))
+				inner.ToValue([]any{"released"})
+			})
+			kept, _ := escaped.Object().GetProperty("kept")
+			assert.Equal(t, true, kept.ToNative())
+			escaped = scope.Escape(escaped)
+		})
+		runtime.RunGC()
+		assert.Equal(t, baseline+1, runtime.GetMemoryUsage().ObjCount)
+		escaped.Free()
+		fn.Free()
+		runtime.RunGC()
+		assert.Equal(t, baseline, runtime.GetMemoryUsage().ObjCount) // fn still held by Eval
+	})
+	guard.Free()
+	runtime.Free()
+}
@@ -6,14 +6,11 @@ import "C"
 type Set struct{ Object }
 
 func (s Set) Size() int {
-	property, _ := s.GetProperty("size")
-	return property.ToPrimitive().(int)
+	return s.context.consumeNative(s.getProperty("size")).(int)
 }
 
 func (s Set) ToNative() []any {
-	arrayFn, _ := s.context.GlobalObject().GetProperty("Array")
-	from, _ := arrayFn.Object().GetProperty("from")
-	callValue := s.context.assert(from.Object().call(null, 1, &s.raw))
+	callValue := s.context.assert(s.context.arrayFrom(s.raw))
 	array := Value{s.context, callValue}.Object().Array()
 	length := array.Len()
 	retval := make([]any, length)
@@ -149,3 +149,40 @@ func (v Value) ToNative() any {
 func (v Value) free() {
 	C.JS_FreeValue(v.context.raw, v.raw)
 }
+
+// Return owned reference which stays valid until Free, unlike values
+// returned by Eval or GetProperty which are only valid until next Eval
+// or until the property is changed
+func (v Value) Dup() Value {
+	if v.context == nil {
+		return v
+	}
+	v.context.handles[v.raw]++
+	return Value{v.context, C.JS_DupValue(v.context.raw, v.raw)}
+}
+
+// Reference to be consumed by quickjs, which is duplicated if owned by Dup
+// so that the owner is still able to release it
+func (c *Context) consume(value Value) C.JSValue {
+	if c.handles[value.raw] > 0 {
+		return C.JS_DupValue(c.raw, value.raw)
+	}
+	return value.raw
+}
+
+// Release reference owned by Dup, it is no-op for value not owned or after
+// context is freed. References not released are released by Context.Free
+func (v Value) Free() {
+	if v.context == nil || v.context.free.Load() {
+		return
+	}
+	switch count := v.context.handles[v.raw]; count {
+	case 0:
+		return
+	case 1:
+		delete(v.context.handles, v.raw)
+	default:
+		v.context.handles[v.raw] = count - 1
+	}
+	v.free()
+}
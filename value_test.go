@@ -138,3 +138,31 @@ func BenchmarkGetType(b *testing.B) {
 		}
 	})
 }
+
+func TestValueDup(t *testing.T) {
+	runtime := NewRuntime(Config{ManualFree: true})
+	guard := runtime.NewContext()
+	guard.With(func(context *Context) {
+		value, _ := context.Eval(`({ name: "config" })`)
+		config := value.Dup()
+		_, _ = context.Eval(`new Array(1024).fill({})`)
+		runtime.RunGC()
+		name, _ := config.Object().GetProperty("name")
+		assert.Equal(t, "config", name.ToNative())
+
+		_, _ = context.Eval(`globalThis.holder = { name: "held" }`)
+		global := context.GlobalObject()
+		property, _ := global.GetProperty("holder")
+		held := property.Dup()
+		global.DeleteProperty("holder")
+		runtime.RunGC()
+		name, _ = held.Object().GetProperty("name")
+		assert.Equal(t, "held", name.ToNative())
+		held.Free()
+		held.Free() // Not owned anymore
+
+		_ = config.Dup() // Released by Context.Free
+	})
+	guard.Free()
+	runtime.Free()
+}